@@ -1,5 +1,25 @@
 package main
 
+import "fmt"
+
+const (
+	// ASNSchemeDefault assigns a unique ASN to every Leaf pair, ToR and Server.
+	ASNSchemeDefault = "default"
+
+	// ASNSchemeReuse reuses ToR ASNs across leaf pairs (RFC 7938 Section 5.2.4).
+	ASNSchemeReuse = "reuse"
+
+	// ASNSchemeUnique additionally assigns a unique ASN to every Spine.
+	ASNSchemeUnique = "unique"
+
+	// ASNSchemePrivate uses 2-byte private ASNs (64512-65534) for small labs.
+	ASNSchemePrivate = "private"
+)
+
+// AllowLocalASReuse is the number of times a ToR accepts its own ASN in the
+// AS path under the reuse scheme.
+const AllowLocalASReuse = 1
+
 const (
 	// ASN assignments for different node types.
 	ASNSpine      = 4200000000
@@ -8,19 +28,214 @@ const (
 	ASNLeafBase   = 4200001000
 	ASNToRBase    = 4200010000
 	ASNServerBase = 4200100000
+
+	// ASNUniqueSpineBase is the first Spine ASN under the unique scheme.
+	ASNUniqueSpineBase = 4200000100
+
+	// ASNMax is the highest 4-byte ASN usable for assignment.
+	ASNMax = 4294967294
 )
 
+const (
+	// 2-byte private ASN assignments (RFC 6996).
+	ASNPrivateSpine      = 64512
+	ASNPrivateBorderLeaf = 64513
+	ASNPrivateRouter     = 64514
+	ASNPrivateLeafBase   = 64600
+	ASNPrivateToRBase    = 64700
+	ASNPrivateServerBase = 65000
+
+	// ASNPrivateMax is the highest 2-byte private ASN.
+	ASNPrivateMax = 65534
+)
+
+// ASNBases holds the ASN (or first ASN of the range) for each role.
+// It is also used for custom base offsets in the topology definition,
+// where zero means "keep the scheme default".
+type ASNBases struct {
+	Spine      int `yaml:"spine"`
+	BorderLeaf int `yaml:"border_leaf"`
+	Router     int `yaml:"router"`
+	Leaf       int `yaml:"leaf"`
+	ToR        int `yaml:"tor"`
+	Server     int `yaml:"server"`
+}
+
+// ASNPlan assigns AS numbers to nodes according to an allocation scheme.
+type ASNPlan struct {
+	ASNBases
+	Scheme       string
+	UniqueSpines bool // Every Spine gets its own ASN (Spine + index)
+	ReuseToR     bool // ToR ASNs are per leaf pair instead of global
+	Max          int  // Highest assignable ASN
+}
+
+// NewASNPlan returns the plan for a scheme with custom bases applied.
+// Non-zero fields of custom override the scheme defaults.
+func NewASNPlan(scheme string, custom ASNBases) (ASNPlan, error) {
+	p := ASNPlan{
+		ASNBases: ASNBases{
+			Spine:      ASNSpine,
+			BorderLeaf: ASNBorderLeaf,
+			Router:     ASNRouter,
+			Leaf:       ASNLeafBase,
+			ToR:        ASNToRBase,
+			Server:     ASNServerBase,
+		},
+		Scheme: scheme,
+		Max:    ASNMax,
+	}
+
+	switch scheme {
+	case ASNSchemeDefault:
+	case ASNSchemeReuse:
+		p.ReuseToR = true
+	case ASNSchemeUnique:
+		p.Spine = ASNUniqueSpineBase
+		p.UniqueSpines = true
+	case ASNSchemePrivate:
+		p.ASNBases = ASNBases{
+			Spine:      ASNPrivateSpine,
+			BorderLeaf: ASNPrivateBorderLeaf,
+			Router:     ASNPrivateRouter,
+			Leaf:       ASNPrivateLeafBase,
+			ToR:        ASNPrivateToRBase,
+			Server:     ASNPrivateServerBase,
+		}
+		p.Max = ASNPrivateMax
+	default:
+		return ASNPlan{}, fmt.Errorf("unknown ASN scheme %q (must be %s, %s, %s or %s)",
+			scheme, ASNSchemeDefault, ASNSchemeReuse, ASNSchemeUnique, ASNSchemePrivate)
+	}
+
+	p.override(custom)
+	return p, nil
+}
+
+// override replaces bases with the non-zero fields of custom.
+func (p *ASNPlan) override(custom ASNBases) {
+	for _, f := range []struct {
+		dst *int
+		src int
+	}{
+		{&p.Spine, custom.Spine},
+		{&p.BorderLeaf, custom.BorderLeaf},
+		{&p.Router, custom.Router},
+		{&p.Leaf, custom.Leaf},
+		{&p.ToR, custom.ToR},
+		{&p.Server, custom.Server},
+	} {
+		if f.src != 0 {
+			*f.dst = f.src
+		}
+	}
+}
+
+// SpineASN returns the ASN for a Spine.
+func (p ASNPlan) SpineASN(index int) int {
+	if p.UniqueSpines {
+		return p.Spine + index
+	}
+	return p.Spine
+}
+
+// BorderLeafASN returns the ASN shared by all Border Leafs.
+func (p ASNPlan) BorderLeafASN() int {
+	return p.BorderLeaf
+}
+
+// RouterASN returns the ASN shared by all Routers.
+func (p ASNPlan) RouterASN() int {
+	return p.Router
+}
+
 // LeafASN returns the ASN for a leaf pair.
-func LeafASN(pairIndex int) int {
-	return ASNLeafBase + pairIndex
+func (p ASNPlan) LeafASN(pairIndex int) int {
+	return p.Leaf + pairIndex
 }
 
 // ToRASN returns the ASN for a ToR.
-func ToRASN(index int) int {
-	return ASNToRBase + index
+// Under the reuse scheme only the index within the leaf pair is used.
+func (p ASNPlan) ToRASN(pairIndex, torIndex, torsPerPair int) int {
+	if p.ReuseToR {
+		return p.ToR + torIndex
+	}
+	return p.ToR + pairIndex*torsPerPair + torIndex
 }
 
 // ServerASN returns the ASN for a server.
-func ServerASN(index int) int {
-	return ASNServerBase + index
+func (p ASNPlan) ServerASN(index int) int {
+	return p.Server + index
+}
+
+// SpineASNRange returns the Spine ASNs as a BIRD integer set range
+// (e.g. "4200000100..4200000107"), or an empty string if Spines share an ASN.
+func (p ASNPlan) SpineASNRange(numSpines int) string {
+	if !p.UniqueSpines || numSpines == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d..%d", p.SpineASN(0), p.SpineASN(numSpines-1))
+}
+
+// asnRange is a contiguous block of ASNs assigned to one role.
+type asnRange struct {
+	role     string
+	first    int
+	last     int
+	assigned bool
+}
+
+// ranges returns the ASN blocks used by a configuration.
+func (p ASNPlan) ranges(c Config) []asnRange {
+	spines := 1
+	if p.UniqueSpines {
+		spines = c.NumSpines
+	}
+	tors := c.TotalToRs()
+	if p.ReuseToR {
+		tors = c.NumToRsPerLeafPair
+	}
+	return []asnRange{
+		{"spine", p.Spine, p.Spine + spines - 1, c.NumSpines > 0},
+		{"border leaf", p.BorderLeaf, p.BorderLeaf, c.NumBorderLeafs > 0},
+		{"router", p.Router, p.Router, c.NumRouters > 0},
+		{"leaf", p.Leaf, p.Leaf + c.NumLeafPairs - 1, c.NumLeafPairs > 0},
+		{"tor", p.ToR, p.ToR + tors - 1, tors > 0},
+		{"server", p.Server, p.Server + c.TotalServers() - 1, c.TotalServers() > 0},
+	}
+}
+
+// Validate checks that all ASN blocks fit below the scheme maximum and do
+// not overlap.
+func (p ASNPlan) Validate(c Config) error {
+	var used []asnRange
+	for _, r := range p.ranges(c) {
+		if !r.assigned {
+			continue
+		}
+		if r.first < 1 || r.last > p.Max {
+			return fmt.Errorf("%s ASNs %d-%d are outside 1-%d", r.role, r.first, r.last, p.Max)
+		}
+		for _, u := range used {
+			if r.first <= u.last && u.first <= r.last {
+				return fmt.Errorf("%s ASNs %d-%d overlap %s ASNs %d-%d",
+					r.role, r.first, r.last, u.role, u.first, u.last)
+			}
+		}
+		used = append(used, r)
+	}
+	return nil
+}
+
+// ASNSchemeWarning returns a warning about the loop-prevention trade-offs of
+// an ASN scheme, or an empty string if there is nothing to warn about.
+func ASNSchemeWarning(scheme string) string {
+	if scheme != ASNSchemeReuse {
+		return ""
+	}
+	return "ASN scheme \"reuse\" assigns the same ASN to ToRs in different leaf pairs " +
+		"and enables \"allow local as 1\" on ToR uplinks. " +
+		"AS-path loop prevention no longer stops a ToR from accepting paths through another ToR " +
+		"with the same ASN; loops are only prevented by the Leaf and Spine ASNs in the path, " +
+		"and ToR ASNs no longer identify a single device when troubleshooting"
 }
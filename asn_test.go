@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestASNSchemes(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.NumSpines = 4
+	cfg.NumLeafPairs = 2
+
+	for _, scheme := range []string{ASNSchemeDefault, ASNSchemeReuse, ASNSchemeUnique, ASNSchemePrivate} {
+		plan, err := NewASNPlan(scheme, ASNBases{})
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		if err := plan.Validate(cfg); err != nil {
+			t.Errorf("%s: unexpected validation error: %v", scheme, err)
+		}
+	}
+
+	plan, _ := NewASNPlan(ASNSchemeUnique, ASNBases{})
+	if plan.SpineASN(0) == plan.SpineASN(3) {
+		t.Errorf("unique scheme assigned the same ASN to spine0 and spine3")
+	}
+	if got, want := plan.SpineASNRange(cfg.NumSpines), "4200000100..4200000103"; got != want {
+		t.Errorf("SpineASNRange = %q, want %q", got, want)
+	}
+
+	if _, err := NewASNPlan("bogus", ASNBases{}); err == nil {
+		t.Errorf("expected error for unknown scheme")
+	}
+}
+
+func TestASNPlanValidate(t *testing.T) {
+	cfg := DefaultConfig()
+
+	// Custom leaf base colliding with the ToR block
+	plan, _ := NewASNPlan(ASNSchemeDefault, ASNBases{Leaf: ASNToRBase})
+	if err := plan.Validate(cfg); err == nil || !strings.Contains(err.Error(), "overlap") {
+		t.Errorf("expected overlap error, got %v", err)
+	}
+
+	// Too many servers for the 2-byte private range
+	cfg.NumLeafPairs = 20
+	cfg.NumToRsPerLeafPair = 20
+	cfg.NumServersPerToR = 4
+	plan, _ = NewASNPlan(ASNSchemePrivate, ASNBases{})
+	if err := plan.Validate(cfg); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("expected range error, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	// BGPAuthNone disables TCP-MD5 authentication.
+	BGPAuthNone = "none"
+
+	// BGPAuthTier uses one password per tier pair (e.g. all Spine-Leaf sessions).
+	BGPAuthTier = "tier"
+
+	// BGPAuthSession uses a different password for every session.
+	BGPAuthSession = "session"
+)
+
+// passwordLength is the number of hex characters in a derived password.
+// Linux accepts TCP-MD5 keys of up to 80 bytes.
+const passwordLength = 32
+
+// AuthFile holds BGP authentication secrets loaded from the file given by
+// -bgp-auth-file. Kept separate from the topology definition so that it can
+// be handled as a secret.
+type AuthFile struct {
+	Seed     string            `yaml:"seed"`     // Secret used to derive passwords
+	Tiers    map[string]string `yaml:"tiers"`    // Password per tier key (tier mode)
+	Sessions map[string]string `yaml:"sessions"` // Password per local "node#iface" (overrides one side only)
+}
+
+// LoadAuthFile loads BGP authentication secrets from a YAML file.
+func LoadAuthFile(path string) (AuthFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AuthFile{}, err
+	}
+
+	var a AuthFile
+	if err := yaml.UnmarshalWithOptions(data, &a, yaml.DisallowUnknownField()); err != nil {
+		return AuthFile{}, err
+	}
+
+	return a, nil
+}
+
+// BGPAuth assigns TCP-MD5 passwords to BGP sessions.
+type BGPAuth struct {
+	Mode string
+	AuthFile
+}
+
+// NewBGPAuth returns the authentication settings for a configuration.
+// The -bgp-auth-seed flag takes precedence over the seed in the file.
+func NewBGPAuth(c Config) BGPAuth {
+	a := BGPAuth{Mode: c.BGPAuth, AuthFile: c.Auth}
+	if c.BGPAuthSeed != "" {
+		a.Seed = c.BGPAuthSeed
+	}
+	return a
+}
+
+// Validate checks the authentication mode and that a seed is available.
+func (a BGPAuth) Validate() error {
+	switch a.Mode {
+	case BGPAuthNone:
+		return nil
+	case BGPAuthTier, BGPAuthSession:
+	default:
+		return fmt.Errorf("unknown BGP auth mode %q (must be %s, %s or %s)",
+			a.Mode, BGPAuthNone, BGPAuthTier, BGPAuthSession)
+	}
+	if a.Seed == "" {
+		return fmt.Errorf("-bgp-auth %s requires -bgp-auth-seed or a seed in -bgp-auth-file", a.Mode)
+	}
+	for key := range a.Tiers {
+		if !ValidTierKey(key) {
+			return fmt.Errorf("invalid tier key %q in BGP auth file (e.g. spine-leaf)", key)
+		}
+	}
+	return nil
+}
+
+// Password returns the password the local side of a session is configured
+// with, or an empty string if authentication is disabled.
+func (a BGPAuth) Password(local, localIf, peer, peerIf string) string {
+	if a.Mode == BGPAuthNone {
+		return ""
+	}
+	if pw, ok := a.Sessions[local+"#"+localIf]; ok {
+		return pw
+	}
+
+	if a.Mode == BGPAuthTier {
+		tier := TierKey(NodeRole(local), NodeRole(peer))
+		if pw, ok := a.Tiers[tier]; ok {
+			return pw
+		}
+		return derivePassword(a.Seed, "tier:"+tier)
+	}
+	return derivePassword(a.Seed, "session:"+SessionKey(local, localIf, peer, peerIf))
+}
+
+// TTLSecurityDefinition enables TTL security (GTSM) per tier or link in the
+// topology definition.
+type TTLSecurityDefinition struct {
+	Tiers map[string]bool `yaml:"tiers"` // Enabled per tier key (e.g. spine-leaf)
+	Links map[string]bool `yaml:"links"` // Enabled per link, keyed by either end "node#iface"
+}
+
+// TTLSecurityPlan assigns TTL security to BGP sessions.
+type TTLSecurityPlan struct {
+	Default bool // -ttl-security
+	Tiers   map[string]bool
+	Links   map[string]bool
+}
+
+// NewTTLSecurityPlan returns the TTL security plan for a configuration.
+func NewTTLSecurityPlan(c Config) TTLSecurityPlan {
+	return TTLSecurityPlan{
+		Default: c.TTLSecurity,
+		Tiers:   c.Definition.TTLSecurity.Tiers,
+		Links:   c.Definition.TTLSecurity.Links,
+	}
+}
+
+// Validate checks the tier and link keys.
+func (p TTLSecurityPlan) Validate() error {
+	for key := range p.Tiers {
+		if !ValidTierKey(key) {
+			return fmt.Errorf("invalid tier key %q in ttl_security (e.g. spine-leaf)", key)
+		}
+	}
+	for key := range p.Links {
+		if !strings.Contains(key, "#") {
+			return fmt.Errorf("invalid link key %q in ttl_security (e.g. spine0#lf0)", key)
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether a session uses TTL security. A link assignment
+// on either end takes precedence over a tier assignment, which takes
+// precedence over the default.
+func (p TTLSecurityPlan) Enabled(local, localIf, peer, peerIf string) bool {
+	for _, end := range []string{local + "#" + localIf, peer + "#" + peerIf} {
+		if on, ok := p.Links[end]; ok {
+			return on
+		}
+	}
+	if on, ok := p.Tiers[TierKey(NodeRole(local), NodeRole(peer))]; ok {
+		return on
+	}
+	return p.Default
+}
+
+// derivePassword derives a password from the seed with HMAC-SHA256.
+func derivePassword(seed, key string) string {
+	mac := hmac.New(sha256.New, []byte(seed))
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))[:passwordLength]
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestBGPAuthPasswords(t *testing.T) {
+	session := BGPAuth{Mode: BGPAuthSession, AuthFile: AuthFile{Seed: "lab"}}
+
+	// Both ends of a session derive the same password
+	a := session.Password("spine0", "lf0", "leaf1-as4200001000", "sp0")
+	b := session.Password("leaf1-as4200001000", "sp0", "spine0", "lf0")
+	if a == "" || a != b {
+		t.Errorf("session passwords differ between ends: %q, %q", a, b)
+	}
+	if c := session.Password("spine0", "lf1", "leaf2-as4200001000", "sp0"); c == a {
+		t.Errorf("different sessions share password %q", a)
+	}
+
+	// Tier mode shares one password per tier pair
+	tier := BGPAuth{Mode: BGPAuthTier, AuthFile: AuthFile{Seed: "lab"}}
+	if tier.Password("spine0", "lf0", "leaf1-as4200001000", "sp0") !=
+		tier.Password("leaf2-as4200001000", "sp1", "spine1", "lf1") {
+		t.Errorf("spine-leaf sessions should share a tier password")
+	}
+
+	// A session override applies to one side only
+	session.Sessions = map[string]string{"spine0#lf0": "wrong"}
+	if got := session.Password("spine0", "lf0", "leaf1-as4200001000", "sp0"); got != "wrong" {
+		t.Errorf("override not applied: %q", got)
+	}
+	if got := session.Password("leaf1-as4200001000", "sp0", "spine0", "lf0"); got != a {
+		t.Errorf("override leaked to peer side: %q", got)
+	}
+
+	if got := (BGPAuth{Mode: BGPAuthNone}).Password("spine0", "lf0", "leaf1-as4200001000", "sp0"); got != "" {
+		t.Errorf("auth disabled but got password %q", got)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+const (
+	// RoutingPolicyPrefix selects filters that match hardcoded prefix ranges.
+	RoutingPolicyPrefix = "prefix"
+
+	// RoutingPolicyCommunity selects filters that match BGP large communities.
+	RoutingPolicyCommunity = "community"
+)
+
+// Large community data parts (second field) used to classify routes.
+// Format: (fabric ASN, data part, value)
+const (
+	CommunityRole = 1 // Value is the role code of the originating node
+	CommunityPod  = 2 // Value is the leaf pair index of the originating node
+	CommunityRack = 3 // Value is the global ToR index of the originating node
+
+	// CommunityExternal marks the external prefixes a Router originates, so
+	// that Border Leafs keep them out of the fabric. Value is always 1.
+	CommunityExternal = 4
+)
+
+// Role codes carried in the CommunityRole data part.
+const (
+	RoleCodeSpine  = 1
+	RoleCodeLeaf   = 2
+	RoleCodeBL     = 3
+	RoleCodeToR    = 4
+	RoleCodeServer = 5
+	RoleCodeRouter = 6
+)
+
+// communityParts maps data part names (as used in BIRD defines) to values.
+var communityParts = map[string]int{
+	"ROLE":     CommunityRole,
+	"POD":      CommunityPod,
+	"RACK":     CommunityRack,
+	"EXTERNAL": CommunityExternal,
+}
+
+// communityRoles maps role names (as used in BIRD defines) to role codes.
+var communityRoles = map[string]int{
+	"SPINE":  RoleCodeSpine,
+	"LEAF":   RoleCodeLeaf,
+	"BL":     RoleCodeBL,
+	"TOR":    RoleCodeToR,
+	"SERVER": RoleCodeServer,
+	"ROUTER": RoleCodeRouter,
+}
+
+// Community holds the BGP large community values a node tags its
+// originated routes with.
+type Community struct {
+	Fabric int            // Global administrator (fabric ASN)
+	Role   int            // Role code of the node
+	Pod    int            // Leaf pair index, or -1 if not applicable
+	Rack   int            // Global ToR index, or -1 if not applicable
+	Parts  map[string]int // All data parts by name
+	Roles  map[string]int // All role codes by name
+}
+
+// NodeCommunity returns the community values for a node.
+// fabric is the global administrator (the first Spine ASN).
+// Pass -1 for pod or rack when the role is not part of a pod or rack.
+func NodeCommunity(fabric, roleCode, pod, rack int) Community {
+	return Community{
+		Fabric: fabric,
+		Role:   roleCode,
+		Pod:    pod,
+		Rack:   rack,
+		Parts:  communityParts,
+		Roles:  communityRoles,
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 )
 
 // Config holds the topology configuration.
@@ -17,42 +18,251 @@ type Config struct {
 	BirdTemplates     string
 	ExternalNetwork   bool
 	ExternalInterface string
+
+	RoutingMode   string
+	RoutingPolicy string
+	ServerMode    string
+	RackMode      string
+
+	NumberedLinks string // Comma-separated tier keys with numbered links, or "all"
+
+	Aggregate            bool
+	AggregateSummaryOnly bool
+
+	WorkloadPrefixLen int // Length of the workload subnet per server (0 = none)
+
+	ASNScheme string
+	ASNReport bool
+
+	IPAMJSON string // Path of the JSON IPAM export (empty = none)
+	IPAMCSV  string // Path of the CSV IPAM export (empty = none)
+
+	TopologyFile string
+	Definition   Definition // Loaded from TopologyFile
+
+	FabricID int // Last byte of generated MACs (0 = fabric_id of the definition)
+
+	BGPAuth     string
+	BGPAuthSeed string
+	BGPAuthFile string
+	Auth        AuthFile // Loaded from BGPAuthFile
+	TTLSecurity bool
+
+	TimerProfile string
+
+	ECMPHash       string
+	ECMPMergePaths int
+
+	MRTNodes  string // Comma-separated node globs
+	MRTPeriod int    // Seconds between table dumps
+
+	ReceiveLimitAction   string
+	ReceiveLimitHeadroom int // Percent over the expected prefix count
+
+	RPKIROAFile string
+	RPKICache   string
+	ROAs        ROAFile // Loaded from RPKIROAFile
+
+	// Set by the drain subcommand
+	DrainNode    string
+	DrainMethod  string
+	DrainPrepend int
 }
 
 // DefaultConfig returns the default configuration (small for testing).
 func DefaultConfig() Config {
 	return Config{
-		NumSpines:          2,
-		NumLeafPairs:       1,
-		NumToRsPerLeafPair: 2,
-		NumServersPerToR:   2,
-		NumBorderLeafs:     1,
-		NumRouters:         1,
-		BirdConfigDir:      "./output",
-		BirdTemplates:      "templates.yaml",
-		ExternalNetwork:    false,
-		ExternalInterface:  "",
+		NumSpines:            2,
+		NumLeafPairs:         1,
+		NumToRsPerLeafPair:   2,
+		NumServersPerToR:     2,
+		NumBorderLeafs:       1,
+		NumRouters:           1,
+		BirdConfigDir:        "./output",
+		BirdTemplates:        "templates.yaml",
+		ExternalNetwork:      false,
+		ExternalInterface:    "",
+		RoutingMode:          RoutingModeBGP,
+		ServerMode:           ServerModeBGP,
+		RackMode:             RackModeL3,
+		RoutingPolicy:        RoutingPolicyPrefix,
+		ASNScheme:            ASNSchemeDefault,
+		BGPAuth:              BGPAuthNone,
+		TimerProfile:         TimerProfileDefault,
+		ReceiveLimitAction:   ReceiveLimitWarn,
+		ReceiveLimitHeadroom: DefaultReceiveLimitHeadroom,
+		MRTPeriod:            DefaultMRTPeriod,
 	}
 }
 
 // ParseFlags parses command line flags and returns a Config.
 func ParseFlags() Config {
 	cfg := DefaultConfig()
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	return cfg
+}
 
-	flag.IntVar(&cfg.NumSpines, "spines", cfg.NumSpines, "Number of spine switches")
-	flag.IntVar(&cfg.NumLeafPairs, "leaf-pairs", cfg.NumLeafPairs, "Number of leaf switch pairs")
-	flag.IntVar(&cfg.NumToRsPerLeafPair, "tors-per-pair", cfg.NumToRsPerLeafPair, "Number of ToR switches per leaf pair")
-	flag.IntVar(&cfg.NumServersPerToR, "servers-per-tor", cfg.NumServersPerToR, "Number of servers per ToR switch")
-	flag.IntVar(&cfg.NumBorderLeafs, "border-leaves", cfg.NumBorderLeafs, "Number of border leaf switches")
-	flag.IntVar(&cfg.NumRouters, "routers", cfg.NumRouters, "Number of external routers")
-	flag.StringVar(&cfg.BirdConfigDir, "bird-config-dir", cfg.BirdConfigDir, "Directory to output BIRD configuration files")
-	flag.StringVar(&cfg.BirdTemplates, "bird-templates", cfg.BirdTemplates, "Path to BIRD templates YAML file")
-	flag.BoolVar(&cfg.ExternalNetwork, "external-network", cfg.ExternalNetwork, "Enable external network connectivity via OVS bridge")
-	flag.StringVar(&cfg.ExternalInterface, "external-interface", cfg.ExternalInterface, "Host interface for external network (required with -external-network)")
+// RegisterFlags registers the topology flags on fs.
+// Subcommands register the same flags so that they see the same topology.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.NumSpines, "spines", c.NumSpines, "Number of spine switches")
+	fs.IntVar(&c.NumLeafPairs, "leaf-pairs", c.NumLeafPairs, "Number of leaf switch pairs")
+	fs.IntVar(&c.NumToRsPerLeafPair, "tors-per-pair", c.NumToRsPerLeafPair, "Number of ToR switches per leaf pair")
+	fs.IntVar(&c.NumServersPerToR, "servers-per-tor", c.NumServersPerToR, "Number of servers per ToR switch")
+	fs.IntVar(&c.NumBorderLeafs, "border-leaves", c.NumBorderLeafs, "Number of border leaf switches")
+	fs.IntVar(&c.NumRouters, "routers", c.NumRouters, "Number of external routers")
+	fs.StringVar(&c.BirdConfigDir, "bird-config-dir", c.BirdConfigDir, "Directory to output BIRD configuration files")
+	fs.StringVar(&c.BirdTemplates, "bird-templates", c.BirdTemplates, "Path to BIRD templates YAML file")
+	fs.BoolVar(&c.ExternalNetwork, "external-network", c.ExternalNetwork, "Enable external network connectivity via OVS bridge")
+	fs.StringVar(&c.ExternalInterface, "external-interface", c.ExternalInterface, "Host interface for external network (required with -external-network)")
+	fs.StringVar(&c.RoutingMode, "routing-mode", c.RoutingMode, "Underlay routing protocol: bgp, ospf or babel")
+	fs.StringVar(&c.NumberedLinks, "numbered-links", c.NumberedLinks, "Comma-separated tiers (e.g. spine-leaf,leaf-tor) or all with numbered /31 links instead of BGP unnumbered")
+	fs.StringVar(&c.RoutingPolicy, "routing-policy", c.RoutingPolicy, "Route filtering policy: prefix or community")
+	fs.StringVar(&c.RackMode, "rack-mode", c.RackMode, "Rack access: l3 (routed link per server) or l2 (ToR bridge with a gateway on the rack subnet)")
+	fs.StringVar(&c.ServerMode, "server-mode", c.ServerMode, "Server routing: bgp, or static (default route via the ToR, which originates server routes)")
+	fs.BoolVar(&c.Aggregate, "aggregate", c.Aggregate, "Announce rack aggregates from ToRs and pod aggregates from leafs")
+	fs.BoolVar(&c.AggregateSummaryOnly, "aggregate-summary-only", c.AggregateSummaryOnly, "Suppress more-specific server routes covered by aggregates (requires -aggregate)")
+	fs.IntVar(&c.WorkloadPrefixLen, "workload-prefix-len", c.WorkloadPrefixLen, "Give each server a workload subnet of this length on a bridge, advertised via BGP (0 = none)")
+	fs.StringVar(&c.ASNScheme, "asn-scheme", c.ASNScheme, "ASN allocation scheme: default, reuse, unique or private")
+	fs.BoolVar(&c.ASNReport, "asn-report", c.ASNReport, "Print the ASN map to stderr")
+	fs.StringVar(&c.IPAMJSON, "ipam-json", c.IPAMJSON, "Write every allocated address and identifier to a JSON file (optional)")
+	fs.StringVar(&c.IPAMCSV, "ipam-csv", c.IPAMCSV, "Write every allocated address and identifier to a CSV file (optional)")
+	fs.StringVar(&c.TopologyFile, "topology", c.TopologyFile, "Path to topology definition YAML file (optional)")
+	fs.IntVar(&c.FabricID, "fabric-id", c.FabricID, "Fabric ID (0-255) folded into generated MACs and LLAs, unique per lab on a host")
+	fs.StringVar(&c.BGPAuth, "bgp-auth", c.BGPAuth, "BGP TCP-MD5 authentication: none, tier or session")
+	fs.StringVar(&c.BGPAuthSeed, "bgp-auth-seed", c.BGPAuthSeed, "Secret seed for deriving BGP passwords")
+	fs.StringVar(&c.BGPAuthFile, "bgp-auth-file", c.BGPAuthFile, "Path to BGP auth YAML file with seed and password overrides (optional)")
+	fs.BoolVar(&c.TTLSecurity, "ttl-security", c.TTLSecurity, "Enable TTL security (GTSM) on BGP sessions not assigned in the topology definition")
+	fs.StringVar(&c.ReceiveLimitAction, "receive-limit-action", c.ReceiveLimitAction, "Action when a receive limit is exceeded: warn, block, restart or disable")
+	fs.IntVar(&c.ReceiveLimitHeadroom, "receive-limit-headroom", c.ReceiveLimitHeadroom, "Receive limit headroom over the expected prefix count (percent)")
+	fs.StringVar(&c.RPKIROAFile, "rpki-roa-file", c.RPKIROAFile, "Path to ROA JSON/YAML file loaded as static ROAs into the roa_v4 table checked on border leaf and router imports (optional)")
+	fs.StringVar(&c.RPKICache, "rpki-cache", c.RPKICache, "External RTR cache HOST[:PORT] feeding the roa_v4 table of border leaves and routers; the cache is not part of the generated topology (optional)")
+	fs.StringVar(&c.TimerProfile, "timer-profile", c.TimerProfile, "Default BFD/BGP timer profile: aggressive, default, relaxed or a custom profile")
+	fs.StringVar(&c.ECMPHash, "ecmp-hash", c.ECMPHash, "Multipath hash policy on all nodes: l3, l4 or inner (default: kernel default)")
+	fs.IntVar(&c.ECMPMergePaths, "ecmp-merge-paths", c.ECMPMergePaths, "Maximum next hops per kernel route (default: BIRD default)")
+	fs.StringVar(&c.MRTNodes, "mrt-nodes", c.MRTNodes, "Comma-separated node globs that write MRT table dumps and BGP message logs")
+	fs.IntVar(&c.MRTPeriod, "mrt-period", c.MRTPeriod, "Seconds between MRT table dumps")
+}
 
-	flag.Parse()
+// Validate checks the configuration for invalid option combinations.
+func (c Config) Validate() error {
+	if c.ExternalNetwork && c.ExternalInterface == "" {
+		return fmt.Errorf("-external-interface is required when -external-network is enabled")
+	}
 
-	return cfg
+	switch c.RoutingPolicy {
+	case RoutingPolicyPrefix, RoutingPolicyCommunity:
+	default:
+		return fmt.Errorf("unknown routing policy %q (must be %s or %s)",
+			c.RoutingPolicy, RoutingPolicyPrefix, RoutingPolicyCommunity)
+	}
+
+	if err := c.validateRoutingMode(); err != nil {
+		return err
+	}
+
+	if err := c.validateServerMode(); err != nil {
+		return err
+	}
+
+	if err := c.validateRackMode(); err != nil {
+		return err
+	}
+
+	if c.AggregateSummaryOnly && !c.Aggregate {
+		return fmt.Errorf("-aggregate-summary-only requires -aggregate")
+	}
+	addrs, err := NewAddressPlan(c.Definition.Addresses)
+	if err != nil {
+		return err
+	}
+	if err := addrs.Validate(c); err != nil {
+		return err
+	}
+	if err := c.validateNumbered(addrs); err != nil {
+		return err
+	}
+	if err := c.validateWorkloads(addrs); err != nil {
+		return err
+	}
+
+	plan, err := NewASNPlan(c.ASNScheme, c.Definition.ASN)
+	if err != nil {
+		return err
+	}
+	if err := plan.Validate(c); err != nil {
+		return err
+	}
+
+	if c.BGPAuthFile != "" && c.BGPAuth == BGPAuthNone {
+		return fmt.Errorf("-bgp-auth-file requires -bgp-auth %s or %s", BGPAuthTier, BGPAuthSession)
+	}
+	if err := NewBGPAuth(c).Validate(); err != nil {
+		return err
+	}
+	if err := NewTTLSecurityPlan(c).Validate(); err != nil {
+		return err
+	}
+
+	if err := NewTimerPlan(c).Validate(); err != nil {
+		return err
+	}
+
+	if err := c.validateECMP(); err != nil {
+		return err
+	}
+
+	if err := c.validateMRT(); err != nil {
+		return err
+	}
+
+	if err := c.validateReceiveLimit(); err != nil {
+		return err
+	}
+
+	if err := c.Definition.Egress.Validate(c); err != nil {
+		return err
+	}
+
+	if err := c.Definition.Policies.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Definition.ExternalPrefixes.Validate(c, addrs); err != nil {
+		return err
+	}
+
+	if _, err := NewRPKI(c); err != nil {
+		return err
+	}
+
+	if err := c.Definition.Tenants.Validate(addrs); err != nil {
+		return err
+	}
+
+	if err := c.validateDrain(); err != nil {
+		return err
+	}
+
+	if err := c.validateFabricID(); err != nil {
+		return err
+	}
+
+	if err := c.validateLinkIDs(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Warnings returns warnings about risky but valid option combinations.
+func (c Config) Warnings() []string {
+	var warnings []string
+	if w := ASNSchemeWarning(c.ASNScheme); w != "" {
+		warnings = append(warnings, w)
+	}
+	return warnings
 }
 
 // TotalNodes returns the total number of nodes in the topology.
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Definition holds optional topology settings that are too detailed for
+// command line flags. It is loaded from the file given by -topology.
+type Definition struct {
+	ASN         ASNBases              `yaml:"asn"`          // Custom ASN base offsets
+	Timers      TimerDefinition       `yaml:"timers"`       // BFD and BGP timer profile assignments
+	TTLSecurity TTLSecurityDefinition `yaml:"ttl_security"` // TTL security (GTSM) assignments
+	Egress      EgressDefinition      `yaml:"egress"`       // Primary/backup Border Leafs and Routers
+	Policies    TEPolicies            `yaml:"policies"`     // Per-session traffic engineering
+
+	ExternalPrefixes ExternalPrefixes `yaml:"external_prefixes"` // Prefixes originated by Routers
+	Tenants          Tenants          `yaml:"tenants"`           // Tenant VRFs
+	ECMP             ECMPDefinition   `yaml:"ecmp"`              // Per-role ECMP settings
+	Addresses        AddressBlocks    `yaml:"addresses"`         // Router ID and anycast address blocks
+	FabricID         int              `yaml:"fabric_id"`         // Fabric ID for generated MACs (-fabric-id overrides)
+}
+
+// LoadDefinition loads a topology definition from a YAML file.
+func LoadDefinition(path string) (Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Definition{}, err
+	}
+
+	var d Definition
+	if err := yaml.UnmarshalWithOptions(data, &d, yaml.DisallowUnknownField()); err != nil {
+		return Definition{}, err
+	}
+
+	return d, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Drain methods.
+const (
+	// DrainGracefulShutdown tags routes with the GRACEFUL_SHUTDOWN community
+	// (RFC 8326); receivers lower their local preference to 0.
+	DrainGracefulShutdown = "gshut"
+
+	// DrainPrepend prepends the local ASN to exported routes.
+	DrainPrepend = "prepend"
+)
+
+// DefaultDrainPrepend is the number of prepends used by the prepend method.
+const DefaultDrainPrepend = 3
+
+// drainActions returns the import and export actions applied to every
+// session of a drained node.
+func (c Config) drainActions() (imp, exp TEAction) {
+	switch c.DrainMethod {
+	case DrainGracefulShutdown:
+		// Tag both directions so that the drained node also prefers
+		// paths that avoid its own sessions (RFC 8326 Section 4.1).
+		return TEAction{GracefulShutdown: true}, TEAction{GracefulShutdown: true}
+	case DrainPrepend:
+		return TEAction{}, TEAction{Prepend: c.DrainPrepend}
+	}
+	return TEAction{}, TEAction{}
+}
+
+// validateDrain checks the drain options.
+func (c Config) validateDrain() error {
+	if c.DrainNode == "" {
+		return nil
+	}
+	switch c.DrainMethod {
+	case DrainGracefulShutdown:
+	case DrainPrepend:
+		if c.DrainPrepend < 1 {
+			return fmt.Errorf("-prepend must be at least 1")
+		}
+	default:
+		return fmt.Errorf("unknown drain method %q (must be %s or %s)",
+			c.DrainMethod, DrainGracefulShutdown, DrainPrepend)
+	}
+	return nil
+}
+
+// runDrain implements the drain subcommand. It regenerates the BIRD
+// configuration of one node with every session drained (or, with -undrain,
+// restored) and prints the commands to apply it to the running lab.
+func runDrain(args []string) {
+	fs := flag.NewFlagSet("drain", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: clos-tinet drain [flags] NODE")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "Topology flags must match the ones used to generate the lab.")
+		fs.PrintDefaults()
+	}
+
+	cfg := DefaultConfig()
+	cfg.RegisterFlags(fs)
+	cfg.DrainMethod = DrainGracefulShutdown
+	cfg.DrainPrepend = DefaultDrainPrepend
+	undrain := fs.Bool("undrain", false, "Restore the normal configuration")
+	fs.StringVar(&cfg.DrainMethod, "method", cfg.DrainMethod, "Drain method: gshut or prepend")
+	fs.IntVar(&cfg.DrainPrepend, "prepend", cfg.DrainPrepend, "Number of prepends for the prepend method")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	node := fs.Arg(0)
+	if !*undrain {
+		cfg.DrainNode = node
+	}
+
+	topo, _, err := buildTopology(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	conf, ok := topo.GetBirdConfigs()[node]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: node %s not found in topology\n", node)
+		os.Exit(1)
+	}
+
+	if err := writeBirdConfigs(cfg.BirdConfigDir, map[string]string{node: conf}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing BIRD configs: %v\n", err)
+		os.Exit(1)
+	}
+
+	path := filepath.Join(cfg.BirdConfigDir, node+".conf")
+	if *undrain {
+		fmt.Printf("# Undrain %s\n", node)
+	} else {
+		fmt.Printf("# Drain %s (%s on %d BGP sessions)\n", node, cfg.DrainMethod, topo.SessionCount(node))
+	}
+	fmt.Printf("# Wrote %s\n", path)
+	fmt.Printf("docker cp %s %s:/etc/bird/bird.conf\n", path, node)
+	fmt.Printf("docker exec %s birdc configure\n", node)
+	fmt.Println()
+	fmt.Println("# Verify")
+	fmt.Printf("docker exec %s birdc show protocols\n", node)
+}
@@ -0,0 +1,91 @@
+package main
+
+import "fmt"
+
+// ECMP hash policies (net.ipv4/ipv6.fib_multipath_hash_policy).
+const (
+	// ECMPHashL3 hashes on source and destination addresses (and the IPv6
+	// flow label).
+	ECMPHashL3 = "l3"
+
+	// ECMPHashL4 hashes on the 5-tuple.
+	ECMPHashL4 = "l4"
+
+	// ECMPHashInner hashes on the inner addresses of encapsulated packets,
+	// or the outer addresses otherwise.
+	ECMPHashInner = "inner"
+)
+
+// ecmpHashPolicies maps hash policy names to sysctl values.
+var ecmpHashPolicies = map[string]int{
+	ECMPHashL3:    0,
+	ECMPHashL4:    1,
+	ECMPHashInner: 2,
+}
+
+// ECMPSettings holds the multipath settings of a node. Zero values leave
+// the kernel and BIRD defaults unchanged.
+type ECMPSettings struct {
+	Hash       string `yaml:"hash"`        // l3, l4 or inner
+	MergePaths int    `yaml:"merge_paths"` // Next hops per kernel route
+}
+
+// ECMPDefinition overrides the -ecmp-hash and -ecmp-merge-paths flags per
+// role in the topology definition.
+type ECMPDefinition struct {
+	Roles map[string]ECMPSettings `yaml:"roles"` // Role (spine, leaf, bl, tor, server, router) -> settings
+}
+
+// ECMP returns the multipath settings of a role: the flags, overridden by
+// the fields set for the role in the topology definition.
+func (c Config) ECMP(role string) ECMPSettings {
+	s := ECMPSettings{Hash: c.ECMPHash, MergePaths: c.ECMPMergePaths}
+	r := c.Definition.ECMP.Roles[role]
+	if r.Hash != "" {
+		s.Hash = r.Hash
+	}
+	if r.MergePaths != 0 {
+		s.MergePaths = r.MergePaths
+	}
+	return s
+}
+
+// Validate checks the hash policy and merge paths limit.
+func (s ECMPSettings) Validate() error {
+	if _, ok := ecmpHashPolicies[s.Hash]; s.Hash != "" && !ok {
+		return fmt.Errorf("unknown ECMP hash policy %q (must be %s, %s or %s)",
+			s.Hash, ECMPHashL3, ECMPHashL4, ECMPHashInner)
+	}
+	if s.MergePaths < 0 {
+		return fmt.Errorf("ECMP merge paths must not be negative")
+	}
+	return nil
+}
+
+// validateECMP checks the ECMP flags and role overrides.
+func (c Config) validateECMP() error {
+	if err := (ECMPSettings{Hash: c.ECMPHash, MergePaths: c.ECMPMergePaths}).Validate(); err != nil {
+		return err
+	}
+	for role, s := range c.Definition.ECMP.Roles {
+		if _, ok := tierOrder[role]; !ok {
+			return fmt.Errorf("ecmp: unknown role %q", role)
+		}
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("ecmp role %s: %w", role, err)
+		}
+	}
+	return nil
+}
+
+// Sysctls returns the commands that set the hash policy for IPv4 and IPv6.
+func (s ECMPSettings) Sysctls() []Command {
+	if s.Hash == "" {
+		return nil
+	}
+	policy := ecmpHashPolicies[s.Hash]
+	return []Command{
+		{Cmd: fmt.Sprintf("sysctl -w net.ipv4.fib_multipath_hash_policy=%d", policy)},
+		{Cmd: fmt.Sprintf("sysctl -w net.ipv6.fib_multipath_hash_policy=%d", policy)},
+	}
+}
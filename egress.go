@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Egress methods for steering the default route away from backup
+// Border Leafs or Routers.
+const (
+	// EgressPrepend prepends the backup node's ASN to the default route it exports.
+	EgressPrepend = "prepend"
+
+	// EgressMED sets a higher MED on the default route the backup node exports.
+	EgressMED = "med"
+
+	// EgressLocalPref lowers the local preference of the default route
+	// where it is received from the backup node.
+	EgressLocalPref = "local-pref"
+)
+
+const (
+	// DefaultEgressPrepend is the number of times the backup node prepends its ASN.
+	DefaultEgressPrepend = 3
+
+	// EgressBackupMED is the MED of the default route from a backup node
+	// (primary nodes send no MED, which is treated as 0).
+	EgressBackupMED = 100
+
+	// EgressBackupLocalPref is the local preference of the default route from
+	// a backup node (BIRD's default is 100).
+	EgressBackupLocalPref = 50
+)
+
+// egressUpstream maps a role to the role it receives the default route from.
+var egressUpstream = map[string]string{
+	"spine": "bl",
+	"bl":    "router",
+}
+
+// EgressDefinition marks Border Leafs or Routers as backup exits in the
+// topology definition. Nodes not listed are primary.
+type EgressDefinition struct {
+	Method  string   `yaml:"method"`  // prepend (default), med or local-pref
+	Backup  []string `yaml:"backup"`  // Backup node names (e.g. bl1, router1)
+	Prepend int      `yaml:"prepend"` // Prepend count for the prepend method (default 3)
+}
+
+// method returns the egress method, defaulting to prepend.
+func (e EgressDefinition) method() string {
+	if e.Method == "" {
+		return EgressPrepend
+	}
+	return e.Method
+}
+
+// IsBackup reports whether a node is a backup exit.
+func (e EgressDefinition) IsBackup(name string) bool {
+	return slices.Contains(e.Backup, name)
+}
+
+// Validate checks the egress method and that backup nodes exist.
+func (e EgressDefinition) Validate(c Config) error {
+	switch e.method() {
+	case EgressPrepend, EgressMED, EgressLocalPref:
+	default:
+		return fmt.Errorf("unknown egress method %q (must be %s, %s or %s)",
+			e.Method, EgressPrepend, EgressMED, EgressLocalPref)
+	}
+	if e.Prepend < 0 {
+		return fmt.Errorf("egress prepend must not be negative")
+	}
+
+	for _, name := range e.Backup {
+		var idx int
+		switch {
+		case scanIndex(name, "bl%d", &idx):
+			if idx >= c.NumBorderLeafs {
+				return fmt.Errorf("egress backup %s does not exist", name)
+			}
+		case scanIndex(name, "router%d", &idx):
+			if idx >= c.NumRouters {
+				return fmt.Errorf("egress backup %s does not exist", name)
+			}
+		default:
+			return fmt.Errorf("egress backup %q must be a Border Leaf or Router", name)
+		}
+	}
+	return nil
+}
+
+// ExportPrepend returns the number of times a node prepends its ASN to the
+// default route it exports.
+func (e EgressDefinition) ExportPrepend(name string) int {
+	if e.method() != EgressPrepend || !e.IsBackup(name) {
+		return 0
+	}
+	if e.Prepend == 0 {
+		return DefaultEgressPrepend
+	}
+	return e.Prepend
+}
+
+// ExportMED returns the MED a node sets on the default route it exports.
+func (e EgressDefinition) ExportMED(name string) int {
+	if e.method() != EgressMED || !e.IsBackup(name) {
+		return 0
+	}
+	return EgressBackupMED
+}
+
+// ImportLocalPref returns the local preference a node sets on the default
+// route received from a peer, or 0 to leave it unchanged.
+func (e EgressDefinition) ImportLocalPref(local, peer string) int {
+	if e.method() != EgressLocalPref || !e.IsBackup(peer) {
+		return 0
+	}
+	if egressUpstream[NodeRole(local)] != NodeRole(peer) {
+		return 0
+	}
+	return EgressBackupLocalPref
+}
+
+// scanIndex parses a node name of the form given by format (e.g. "bl%d").
+func scanIndex(name, format string, idx *int) bool {
+	if n, err := fmt.Sscanf(name, format, idx); err != nil || n != 1 {
+		return false
+	}
+	return fmt.Sprintf(format, *idx) == name
+}
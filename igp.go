@@ -0,0 +1,84 @@
+package main
+
+import "fmt"
+
+// Routing modes for the fabric underlay.
+const (
+	// RoutingModeBGP runs eBGP on every link (RFC 7938).
+	RoutingModeBGP = "bgp"
+
+	// RoutingModeOSPF runs OSPFv3 with the IPv4 address family on every
+	// link, as unnumbered point-to-point interfaces.
+	RoutingModeOSPF = "ospf"
+
+	// RoutingModeBabel runs Babel with IPv4 routes over IPv6 next hops.
+	RoutingModeBabel = "babel"
+)
+
+// validateRoutingMode checks the routing mode and rejects BGP-only options
+// in IGP modes.
+func (c Config) validateRoutingMode() error {
+	switch c.RoutingMode {
+	case RoutingModeBGP:
+		return nil
+	case RoutingModeOSPF, RoutingModeBabel:
+	default:
+		return fmt.Errorf("unknown routing mode %q (must be %s, %s or %s)",
+			c.RoutingMode, RoutingModeBGP, RoutingModeOSPF, RoutingModeBabel)
+	}
+
+	bgpOnly := []struct {
+		set  bool
+		name string
+	}{
+		{c.RoutingPolicy != RoutingPolicyPrefix, "-routing-policy " + c.RoutingPolicy},
+		{c.AggregateSummaryOnly, "-aggregate-summary-only"},
+		{c.BGPAuth != BGPAuthNone, "-bgp-auth"},
+		{c.TTLSecurity, "-ttl-security"},
+		{c.RPKIROAFile != "" || c.RPKICache != "", "RPKI"},
+		{len(c.Definition.Egress.Backup) > 0, "egress backups"},
+		{len(c.Definition.Policies) > 0, "traffic engineering policies"},
+		{c.DrainNode != "", "drain"},
+		{len(c.Definition.Tenants) > 0, "tenants"},
+		{c.NumberedLinks != "", "-numbered-links"},
+	}
+	for _, o := range bgpOnly {
+		if o.set {
+			return fmt.Errorf("%s requires -routing-mode %s", o.name, RoutingModeBGP)
+		}
+	}
+	return nil
+}
+
+// addUnnumberedAddrs gives every fabric interface between IGP speakers the
+// node's router ID as a /32 with the neighbor's router ID as peer, so that
+// OSPFv3 has IPv4 next hops on unnumbered point-to-point links. Must run
+// after all nodes are built.
+func (t *Topology) addUnnumberedAddrs() {
+	routerIDs := make(map[string]string)
+	for _, n := range t.nodes {
+		routerIDs[n.Name] = n.RouterID
+	}
+
+	for i := range t.nodeConfigs {
+		nc := &t.nodeConfigs[i]
+		if _, ok := t.birdConfigs[nc.Name]; !ok {
+			continue
+		}
+		var cmds []Command
+		for _, iface := range t.interfaces[nc.Name] {
+			info, ok := t.peerLLAs[nc.Name][iface.Name]
+			if !ok {
+				continue
+			}
+			if _, ok := t.birdConfigs[info.PeerNode]; !ok {
+				continue
+			}
+			cmds = append(cmds, Command{Cmd: fmt.Sprintf("ip addr add %s/32 peer %s/32 dev %s",
+				routerIDs[nc.Name], routerIDs[info.PeerNode], iface.Name)})
+		}
+
+		// Before the daemon starts
+		nc.Cmds = append(cmds, nc.Cmds...)
+	}
+}
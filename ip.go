@@ -1,45 +1,361 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"net/netip"
+)
 
+// Default address plan. Infrastructure loopbacks share one supernet so
+// that a single prefix set matches every Spine, Leaf, ToR, Border Leaf and
+// Router.
 const (
-	// AnycastAddress is the anycast address advertised by all servers.
-	AnycastAddress = "10.100.0.1"
+	DefaultLoopbackBlock   = "10.255.0.0/16"
+	DefaultSpineBlock      = "10.255.0.0/24"
+	DefaultLeafBlock       = "10.255.1.0/24"
+	DefaultToRBlock        = "10.255.2.0/23"
+	DefaultBorderLeafBlock = "10.255.254.0/24"
+	DefaultRouterBlock     = "10.255.255.0/24"
+	DefaultServerBlock     = "10.0.0.0/16"
+	DefaultAnycastBlock    = "10.100.0.0/24"
+	DefaultLinkBlock       = "10.254.0.0/16"
+	DefaultWorkloadBlock   = "10.200.0.0/16"
 )
 
-// SpineRouterID returns the router ID for a spine.
-func SpineRouterID(index int) string {
-	return fmt.Sprintf("10.255.0.%d", index+1)
+// AddressBlocks holds the address plan of the topology definition. Empty
+// fields keep the default block.
+type AddressBlocks struct {
+	Loopbacks  string `yaml:"loopbacks"`   // Supernet of all infrastructure router IDs
+	Spine      string `yaml:"spine"`       // Within loopbacks
+	Leaf       string `yaml:"leaf"`        // Within loopbacks
+	ToR        string `yaml:"tor"`         // Within loopbacks
+	BorderLeaf string `yaml:"border_leaf"` // Within loopbacks
+	Router     string `yaml:"router"`      // Within loopbacks
+	Server     string `yaml:"server"`      // Server router IDs and aggregates
+	Anycast    string `yaml:"anycast"`     // First address is the server anycast address
+	Links      string `yaml:"links"`       // /31 per numbered link
+	Links6     string `yaml:"links6"`      // /127 per numbered link (IPv6, optional)
+	Workloads  string `yaml:"workloads"`   // Workload subnet per server
+}
+
+// AddressBlock is an IPv4 prefix of the address plan.
+type AddressBlock struct {
+	netip.Prefix
+}
+
+// Any returns the block as a BIRD prefix pattern matching the block and
+// every prefix within it.
+func (b AddressBlock) Any() string {
+	return fmt.Sprintf("%s{%d,32}", b.Prefix, b.Bits())
+}
+
+// Hosts returns the block as a BIRD prefix pattern matching host routes
+// within it.
+func (b AddressBlock) Hosts() string {
+	return fmt.Sprintf("%s{32,32}", b.Prefix)
+}
+
+// Subnets returns the block as a BIRD prefix pattern matching its
+// subnets of the given length.
+func (b AddressBlock) Subnets(bits int) string {
+	return fmt.Sprintf("%s{%d,%d}", b.Prefix, bits, bits)
 }
 
-// LeafRouterID returns the router ID for a leaf.
-func LeafRouterID(pairIndex, leafNum int) string {
-	return fmt.Sprintf("10.255.1.%d", pairIndex*2+leafNum)
+// Size returns the number of addresses in the block.
+func (b AddressBlock) Size() int {
+	return 1 << (32 - b.Bits())
 }
 
-// ToRRouterID returns the router ID for a ToR.
-// Supports up to 510 ToRs (10.255.2.1 - 10.255.3.254).
-func ToRRouterID(index int) string {
-	if index < 255 {
-		return fmt.Sprintf("10.255.2.%d", index+1)
+// Capacity returns the number of usable host addresses (excluding the
+// first and last address of the block).
+func (b AddressBlock) Capacity() int {
+	return b.Size() - 2
+}
+
+// offset returns the address n addresses after the start of the block.
+func (b AddressBlock) offset(n int) uint32 {
+	a := b.Addr().As4()
+	return uint32(a[0])<<24 | uint32(a[1])<<16 | uint32(a[2])<<8 | uint32(a[3]) + uint32(n)
+}
+
+// Host returns the n-th host address (from 0) of the block.
+func (b AddressBlock) Host(n int) string {
+	return formatIPv4(b.offset(n + 1))
+}
+
+// NonZeroHost returns the n-th host address (from 0) of the block, skipping
+// addresses ending in .0, so that the 256th host of a /23 is x.x.1.1 and
+// not the network-looking x.x.1.0.
+func (b AddressBlock) NonZeroHost(n int) string {
+	return formatIPv4(b.offset(n + 1 + n/255))
+}
+
+// NonZeroCapacity returns the number of addresses NonZeroHost allocates
+// before reaching the last address of the block.
+func (b AddressBlock) NonZeroCapacity() int {
+	if b.Size() < 256 {
+		return b.Capacity()
 	}
-	return fmt.Sprintf("10.255.3.%d", index-254)
+	return b.Size() - b.Size()/256 - 1
+}
+
+// AddressPlan allocates router IDs, server aggregates and the anycast
+// address, and provides the prefix sets used by the BIRD filters.
+type AddressPlan struct {
+	Loopbacks  AddressBlock
+	Spine      AddressBlock
+	Leaf       AddressBlock
+	ToR        AddressBlock
+	BorderLeaf AddressBlock
+	Router     AddressBlock
+	Server     AddressBlock
+	Anycast    AddressBlock
+	Links      AddressBlock
+	Links6     netip.Prefix // Invalid if numbered links have no IPv6 addresses
+	Workloads  AddressBlock
+}
+
+// NewAddressPlan returns the default plan with custom blocks applied.
+func NewAddressPlan(custom AddressBlocks) (AddressPlan, error) {
+	var p AddressPlan
+	blocks := []struct {
+		name   string
+		dst    *AddressBlock
+		value  string
+		defval string
+	}{
+		{"loopbacks", &p.Loopbacks, custom.Loopbacks, DefaultLoopbackBlock},
+		{"spine", &p.Spine, custom.Spine, DefaultSpineBlock},
+		{"leaf", &p.Leaf, custom.Leaf, DefaultLeafBlock},
+		{"tor", &p.ToR, custom.ToR, DefaultToRBlock},
+		{"border_leaf", &p.BorderLeaf, custom.BorderLeaf, DefaultBorderLeafBlock},
+		{"router", &p.Router, custom.Router, DefaultRouterBlock},
+		{"server", &p.Server, custom.Server, DefaultServerBlock},
+		{"anycast", &p.Anycast, custom.Anycast, DefaultAnycastBlock},
+		{"links", &p.Links, custom.Links, DefaultLinkBlock},
+		{"workloads", &p.Workloads, custom.Workloads, DefaultWorkloadBlock},
+	}
+	for _, b := range blocks {
+		s := b.value
+		if s == "" {
+			s = b.defval
+		}
+		pfx, err := netip.ParsePrefix(s)
+		if err != nil {
+			return AddressPlan{}, fmt.Errorf("address plan %s: %w", b.name, err)
+		}
+		if !pfx.Addr().Is4() || pfx != pfx.Masked() {
+			return AddressPlan{}, fmt.Errorf("address plan %s: %s must be a masked IPv4 prefix", b.name, s)
+		}
+		if pfx.Bits() > 30 {
+			return AddressPlan{}, fmt.Errorf("address plan %s: %s is too small (at most /30)", b.name, s)
+		}
+		*b.dst = AddressBlock{pfx}
+	}
+
+	named := []struct {
+		name     string
+		block    AddressBlock
+		loopback bool // Allocated from the loopbacks supernet
+	}{
+		{"spine", p.Spine, true},
+		{"leaf", p.Leaf, true},
+		{"tor", p.ToR, true},
+		{"border_leaf", p.BorderLeaf, true},
+		{"router", p.Router, true},
+		{"server", p.Server, false},
+		{"anycast", p.Anycast, false},
+		{"links", p.Links, false},
+		{"workloads", p.Workloads, false},
+	}
+	for i, a := range named {
+		inside := a.block.Bits() >= p.Loopbacks.Bits() && p.Loopbacks.Contains(a.block.Addr())
+		if a.loopback && !inside {
+			return AddressPlan{}, fmt.Errorf("address plan %s %s is outside loopbacks %s", a.name, a.block, p.Loopbacks)
+		}
+		if !a.loopback && a.block.Overlaps(p.Loopbacks.Prefix) {
+			return AddressPlan{}, fmt.Errorf("address plan %s %s overlaps loopbacks %s", a.name, a.block, p.Loopbacks)
+		}
+		for _, b := range named[i+1:] {
+			if a.block.Overlaps(b.block.Prefix) {
+				return AddressPlan{}, fmt.Errorf("address plan %s %s overlaps %s %s", a.name, a.block, b.name, b.block)
+			}
+		}
+	}
+
+	if custom.Links6 != "" {
+		pfx, err := netip.ParsePrefix(custom.Links6)
+		if err != nil {
+			return AddressPlan{}, fmt.Errorf("address plan links6: %w", err)
+		}
+		if !pfx.Addr().Is6() || pfx != pfx.Masked() || pfx.Bits() > 127 {
+			return AddressPlan{}, fmt.Errorf("address plan links6: %s must be a masked IPv6 prefix of at most /127", custom.Links6)
+		}
+		p.Links6 = pfx
+	}
+
+	return p, nil
+}
+
+// Validate checks that each block holds the nodes of the configuration
+// and that the plan leaves the external network free.
+func (p AddressPlan) Validate(c Config) error {
+	type need struct {
+		name     string
+		block    AddressBlock
+		n        int
+		capacity int
+	}
+	needs := []need{
+		{"spine", p.Spine, c.NumSpines, p.Spine.Capacity()},
+		{"leaf", p.Leaf, c.NumLeafPairs * 2, p.Leaf.Capacity()},
+		{"tor", p.ToR, c.TotalToRs(), p.ToR.NonZeroCapacity()},
+		{"border_leaf", p.BorderLeaf, c.NumBorderLeafs, p.BorderLeaf.Capacity()},
+		{"router", p.Router, c.NumRouters, p.Router.Capacity()},
+	}
+	if !c.Aggregate && !c.L2Racks() {
+		needs = append(needs, need{"server", p.Server, c.TotalServers(), p.Server.Capacity()})
+	}
+	for _, x := range needs {
+		if x.n > x.capacity {
+			return fmt.Errorf("address plan %s %s holds %d addresses, %d needed",
+				x.name, x.block, x.capacity, x.n)
+		}
+	}
+
+	if c.ExternalNetwork {
+		ext := netip.MustParsePrefix(ExternalNetworkPrefix + ".0/24")
+		if b, ok := p.Overlapping(ext); ok {
+			return fmt.Errorf("address plan %s overlaps the external network %s", b, ext)
+		}
+	}
+
+	if c.Aggregate || c.L2Racks() {
+		podSize := PodRackSlots(c.NumToRsPerLeafPair) * c.rackBlockSize()
+		if c.NumLeafPairs*podSize > p.Server.Size() {
+			return fmt.Errorf("per-rack server blocks need %d addresses, exceeding %s",
+				c.NumLeafPairs*podSize, p.Server)
+		}
+	}
+	return nil
+}
+
+// Overlapping returns the block overlapping pfx, if any.
+func (p AddressPlan) Overlapping(pfx netip.Prefix) (AddressBlock, bool) {
+	for _, b := range []AddressBlock{p.Loopbacks, p.Server, p.Anycast, p.Links, p.Workloads} {
+		if b.Overlaps(pfx) {
+			return b, true
+		}
+	}
+	return AddressBlock{}, false
+}
+
+// SpineRouterID returns the router ID for a spine.
+func (p AddressPlan) SpineRouterID(index int) string {
+	return p.Spine.Host(index)
+}
+
+// LeafRouterID returns the router ID for a leaf (leafNum 1 or 2).
+func (p AddressPlan) LeafRouterID(pairIndex, leafNum int) string {
+	return p.Leaf.Host(pairIndex*2 + leafNum - 1)
+}
+
+// ToRRouterID returns the router ID for a ToR. The ToR block spans more
+// than a /24 by default, so addresses ending in .0 are skipped.
+func (p AddressPlan) ToRRouterID(index int) string {
+	return p.ToR.NonZeroHost(index)
 }
 
 // BorderLeafRouterID returns the router ID for a border leaf.
-func BorderLeafRouterID(index int) string {
-	return fmt.Sprintf("10.255.254.%d", index+1)
+func (p AddressPlan) BorderLeafRouterID(index int) string {
+	return p.BorderLeaf.Host(index)
 }
 
 // RouterRouterID returns the router ID for a router.
-func RouterRouterID(index int) string {
-	return fmt.Sprintf("10.255.255.%d", index+1)
+func (p AddressPlan) RouterRouterID(index int) string {
+	return p.Router.Host(index)
 }
 
 // ServerRouterID returns the router ID for a server.
-// Supports up to 65534 servers (10.0.0.1 - 10.0.255.254).
-func ServerRouterID(index int) string {
-	high := index / 256
-	low := index%256 + 1
-	return fmt.Sprintf("10.0.%d.%d", high, low)
+func (p AddressPlan) ServerRouterID(index int) string {
+	return p.Server.Host(index)
+}
+
+// AnycastAddress returns the anycast address advertised by all servers.
+func (p AddressPlan) AnycastAddress() string {
+	return p.Anycast.Host(0)
+}
+
+// RackBlockSize returns the number of addresses reserved per rack when
+// server addresses are allocated in per-rack blocks.
+// The block is the smallest power of two holding the servers plus the
+// unused first address.
+func RackBlockSize(serversPerToR int) int {
+	return nextPowerOfTwo(serversPerToR + 1)
+}
+
+// RackSubnetSize returns the number of addresses of an L2 rack subnet:
+// the smallest power of two holding the servers, the gateway and the
+// network and broadcast addresses.
+func RackSubnetSize(serversPerToR int) int {
+	return nextPowerOfTwo(serversPerToR + 3)
+}
+
+// rackBlockSize returns the number of server addresses reserved per rack.
+func (c Config) rackBlockSize() int {
+	if c.L2Racks() {
+		return RackSubnetSize(c.NumServersPerToR)
+	}
+	return RackBlockSize(c.NumServersPerToR)
+}
+
+// PodRackSlots returns the number of rack blocks reserved per leaf pair.
+func PodRackSlots(torsPerPair int) int {
+	return nextPowerOfTwo(torsPerPair)
+}
+
+// RackServerRouterID returns the router ID for a server allocated from a
+// per-rack block. rackSlot is the block index (see PodRackSlots).
+func (p AddressPlan) RackServerRouterID(rackSlot, srvIdx, blockSize int) string {
+	return formatIPv4(p.Server.offset(rackSlot*blockSize + srvIdx + 1))
+}
+
+// RackAggregate returns the prefix covering all servers of a rack.
+func (p AddressPlan) RackAggregate(rackSlot, blockSize int) string {
+	return formatPrefix(p.Server.offset(rackSlot*blockSize), blockSize)
+}
+
+// PodAggregate returns the prefix covering all racks of a leaf pair.
+func (p AddressPlan) PodAggregate(pairIdx, rackSlots, blockSize int) string {
+	podSize := rackSlots * blockSize
+	return formatPrefix(p.Server.offset(pairIdx*podSize), podSize)
+}
+
+// nextPowerOfTwo returns the smallest power of two >= n.
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// formatIPv4 formats a 32-bit integer as a dotted IPv4 address.
+func formatIPv4(v uint32) string {
+	return fmt.Sprintf("%d.%d.%d.%d", byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// subnetGateway returns the first host of a subnet with the prefix length,
+// the address a node takes as gateway on the subnet.
+func subnetGateway(subnet string) string {
+	pfx := netip.MustParsePrefix(subnet)
+	return netip.PrefixFrom(pfx.Addr().Next(), pfx.Bits()).String()
+}
+
+// formatPrefix formats a block of size addresses (a power of two) as a prefix.
+func formatPrefix(base uint32, size int) string {
+	length := 32
+	for s := size; s > 1; s >>= 1 {
+		length--
+	}
+	return fmt.Sprintf("%s/%d", formatIPv4(base), length)
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAggregatesCoverServers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.NumLeafPairs = 3
+	cfg.NumToRsPerLeafPair = 3
+	cfg.NumServersPerToR = 7
+	addrs, err := NewAddressPlan(AddressBlocks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockSize := RackBlockSize(cfg.NumServersPerToR)
+	slots := PodRackSlots(cfg.NumToRsPerLeafPair)
+	seen := make(map[string]bool)
+
+	for pairIdx := 0; pairIdx < cfg.NumLeafPairs; pairIdx++ {
+		_, pod, err := net.ParseCIDR(addrs.PodAggregate(pairIdx, slots, blockSize))
+		if err != nil {
+			t.Fatalf("invalid pod aggregate: %v", err)
+		}
+		for torIdx := 0; torIdx < cfg.NumToRsPerLeafPair; torIdx++ {
+			rackSlot := pairIdx*slots + torIdx
+			rackIP, rack, err := net.ParseCIDR(addrs.RackAggregate(rackSlot, blockSize))
+			if err != nil {
+				t.Fatalf("invalid rack aggregate: %v", err)
+			}
+			if !pod.Contains(rackIP) {
+				t.Errorf("rack %s not within pod %s", rack, pod)
+			}
+			for srvIdx := 0; srvIdx < cfg.NumServersPerToR; srvIdx++ {
+				id := addrs.RackServerRouterID(rackSlot, srvIdx, blockSize)
+				if !rack.Contains(net.ParseIP(id)) {
+					t.Errorf("server %s not within rack %s", id, rack)
+				}
+				if seen[id] {
+					t.Errorf("duplicate server router ID %s", id)
+				}
+				seen[id] = true
+			}
+		}
+	}
+}
+
+func TestAddressPlan(t *testing.T) {
+	_, err := NewAddressPlan(AddressBlocks{
+		Loopbacks: "172.16.0.0/20",
+		Spine:     "172.16.0.0/28",
+		Leaf:      "172.16.1.0/26",
+		ToR:       "172.16.2.0/24",
+		Server:    "100.64.0.0/22",
+	})
+	if err == nil {
+		t.Fatal("expected error for default Border Leaf block outside loopbacks")
+	}
+
+	addrs, err := NewAddressPlan(AddressBlocks{
+		Loopbacks:  "172.16.0.0/20",
+		Spine:      "172.16.0.0/28",
+		Leaf:       "172.16.1.0/26",
+		ToR:        "172.16.2.0/24",
+		BorderLeaf: "172.16.15.0/28",
+		Router:     "172.16.15.16/28",
+		Server:     "100.64.0.0/22",
+		Anycast:    "192.0.2.0/30",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct{ got, want string }{
+		{addrs.SpineRouterID(1), "172.16.0.2"},
+		{addrs.LeafRouterID(1, 2), "172.16.1.4"},
+		{addrs.ToRRouterID(0), "172.16.2.1"},
+		{addrs.RouterRouterID(0), "172.16.15.17"},
+		{addrs.ServerRouterID(300), "100.64.1.45"},
+		{addrs.AnycastAddress(), "192.0.2.1"},
+		{addrs.Loopbacks.Any(), "172.16.0.0/20{20,32}"},
+		{addrs.Server.Hosts(), "100.64.0.0/22{32,32}"},
+		{addrs.RackAggregate(1, 8), "100.64.0.8/29"},
+		{addrs.PodAggregate(1, 4, 8), "100.64.0.32/27"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("got %s, want %s", c.got, c.want)
+		}
+	}
+
+	// The default ToR block keeps the original numbering: ToR 255 skips
+	// the .0 address, and the last ToR stops before the /23 broadcast
+	defaults, err := NewAddressPlan(AddressBlocks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, want := range map[int]string{0: "10.255.2.1", 254: "10.255.2.255", 255: "10.255.3.1", 508: "10.255.3.254"} {
+		if got := defaults.ToRRouterID(index); got != want {
+			t.Errorf("ToRRouterID(%d) = %s, want %s", index, got, want)
+		}
+	}
+	if got := defaults.ToR.NonZeroCapacity(); got != 509 {
+		t.Errorf("ToR block holds %d router IDs, want 509", got)
+	}
+
+	cfg := DefaultConfig()
+	cfg.NumSpines = 15
+	if err := addrs.Validate(cfg); err == nil {
+		t.Error("expected error for spine block too small")
+	}
+
+	bad := []AddressBlocks{
+		{Server: "10.0.0.0/33"},
+		{Server: "10.0.0.1/16"},
+		{Server: "10.255.128.0/17"},
+		{Anycast: "10.0.1.0/24"},
+		{Leaf: "10.255.0.128/25"},
+		{Spine: "10.254.0.0/24"},
+		{Router: "10.255.255.252/31"},
+	}
+	for _, b := range bad {
+		if _, err := NewAddressPlan(b); err == nil {
+			t.Errorf("expected error for %+v", b)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IPAM lists every address and identifier allocated by a build, for
+// inventory systems and troubleshooting scripts.
+type IPAM struct {
+	Nodes []IPAMNode `json:"nodes"`
+}
+
+// IPAMNode holds the identifiers of a node.
+type IPAMNode struct {
+	Name       string          `json:"name"`
+	Role       string          `json:"role"`
+	ASN        int             `json:"asn"`
+	RouterID   string          `json:"router_id"`
+	Loopbacks  []string        `json:"loopbacks"`      // Addresses on lo, with prefix length
+	Interfaces []IPAMInterface `json:"interfaces"`     // Fabric links, then the external network and bridges
+	VRFs       []IPAMVRF       `json:"vrfs,omitempty"` // Tenant VRFs on the node
+}
+
+// IPAMInterface holds the addresses of an interface and its peer.
+type IPAMInterface struct {
+	Name          string `json:"name"`
+	MAC           string `json:"mac,omitempty"`
+	LLA           string `json:"lla,omitempty"`
+	Address       string `json:"address,omitempty"`  // IPv4 address with prefix length (numbered links, L2 racks, external network, bridges)
+	Address6      string `json:"address6,omitempty"` // IPv6 /127 (numbered links)
+	PeerNode      string `json:"peer_node"`          // Peer node, or the bridge name (empty for bridges)
+	PeerInterface string `json:"peer_interface,omitempty"`
+}
+
+// IPAMVRF holds the identifiers of a tenant VRF on a node.
+type IPAMVRF struct {
+	Name    string `json:"name"`
+	VLAN    int    `json:"vlan"`
+	Table   int    `json:"table"`
+	Address string `json:"address,omitempty"` // Tenant address (servers)
+}
+
+// IPAM returns the allocations of the built topology in build order.
+// Interfaces of a node are sorted by name.
+func (t *Topology) IPAM() IPAM {
+	var out IPAM
+	for _, n := range t.nodes {
+		node := IPAMNode{
+			Name:      n.Name,
+			Role:      n.Role,
+			ASN:       n.ASN,
+			RouterID:  n.RouterID,
+			Loopbacks: n.Loopbacks,
+		}
+
+		var ifNames []string
+		for ifName := range t.peerLLAs[n.Name] {
+			ifNames = append(ifNames, ifName)
+		}
+		sort.Slice(ifNames, func(i, j int) bool { return lessIfName(ifNames[i], ifNames[j]) })
+		for _, ifName := range ifNames {
+			info := t.peerLLAs[n.Name][ifName]
+			iface := IPAMInterface{
+				Name:          ifName,
+				MAC:           info.LocalMAC,
+				LLA:           info.LocalLLA,
+				PeerNode:      info.PeerNode,
+				PeerInterface: info.PeerIf,
+			}
+			if info.Numbered != nil {
+				iface.Address = info.Numbered.Local + "/31"
+				if info.Numbered.Local6 != "" {
+					iface.Address6 = info.Numbered.Local6 + "/127"
+				}
+			}
+			if info.Address != "" {
+				iface.Address = info.Address
+			}
+			node.Interfaces = append(node.Interfaces, iface)
+		}
+
+		var rtIdx int
+		if t.config.ExternalNetwork && scanIndex(n.Name, "router%d", &rtIdx) {
+			node.Interfaces = append(node.Interfaces, IPAMInterface{
+				Name:     "eth0",
+				Address:  ExternalRouterIP(rtIdx) + "/24",
+				PeerNode: ExternalBridgeName,
+			})
+		}
+		if n.Gateway != "" {
+			node.Interfaces = append(node.Interfaces, IPAMInterface{
+				Name:    RackBridge,
+				MAC:     t.config.AnycastGatewayMAC().String(),
+				Address: n.Gateway,
+			})
+		}
+		if n.Workload != "" {
+			node.Interfaces = append(node.Interfaces, IPAMInterface{
+				Name:    WorkloadBridge,
+				Address: n.Workload,
+			})
+		}
+
+		for _, plan := range t.tenants {
+			if !plan.members[n.Name] {
+				continue
+			}
+			vrf := IPAMVRF{Name: plan.Name, VLAN: plan.ID, Table: TenantTableBase + plan.ID}
+			if addr := plan.addresses[n.Name]; addr != "" {
+				vrf.Address = addr + "/32"
+			}
+			node.VRFs = append(node.VRFs, vrf)
+		}
+
+		out.Nodes = append(out.Nodes, node)
+	}
+	return out
+}
+
+// lessIfName orders interface names by prefix, then by numeric suffix
+// (lf2 before lf10).
+func lessIfName(a, b string) bool {
+	pa, na := splitIfName(a)
+	pb, nb := splitIfName(b)
+	if pa != pb {
+		return pa < pb
+	}
+	return na < nb
+}
+
+// splitIfName splits an interface name into its prefix and numeric suffix.
+func splitIfName(name string) (string, int) {
+	i := len(name)
+	for i > 0 && name[i-1] >= '0' && name[i-1] <= '9' {
+		i--
+	}
+	n, _ := strconv.Atoi(name[i:])
+	return name[:i], n
+}
+
+// WriteIPAMJSON writes the IPAM export as indented JSON.
+func WriteIPAMJSON(path string, ipam IPAM) error {
+	data, err := json.MarshalIndent(ipam, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ipamCSVHeader is the header row of the CSV export.
+var ipamCSVHeader = []string{
+	"node", "role", "asn", "router_id", "loopbacks", "vrfs",
+	"interface", "mac", "lla", "address", "address6", "peer_node", "peer_interface",
+}
+
+// WriteIPAMCSV writes the IPAM export as CSV with one row per interface.
+// Node columns are repeated on each row; lists are space-separated and
+// VRFs are written as name:vlan:table[:address].
+func WriteIPAMCSV(path string, ipam IPAM) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(ipamCSVHeader); err != nil {
+		return err
+	}
+	for _, n := range ipam.Nodes {
+		var vrfs []string
+		for _, v := range n.VRFs {
+			s := fmt.Sprintf("%s:%d:%d", v.Name, v.VLAN, v.Table)
+			if v.Address != "" {
+				s += ":" + v.Address
+			}
+			vrfs = append(vrfs, s)
+		}
+		node := []string{
+			n.Name, n.Role, strconv.Itoa(n.ASN), n.RouterID,
+			strings.Join(n.Loopbacks, " "), strings.Join(vrfs, " "),
+		}
+		for _, i := range n.Interfaces {
+			row := append(append([]string{}, node...),
+				i.Name, i.MAC, i.LLA, i.Address, i.Address6, i.PeerNode, i.PeerInterface)
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
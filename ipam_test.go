@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestIPAM(t *testing.T) {
+	topo, _ := buildTestTopology(t, func(c *Config) { c.NumberedLinks = "leaf-tor" })
+	ipam := topo.IPAM()
+
+	if len(ipam.Nodes) != len(topo.GetNodes()) {
+		t.Fatalf("got %d nodes, want %d", len(ipam.Nodes), len(topo.GetNodes()))
+	}
+
+	ifaces := make(map[string]IPAMInterface) // node#if -> interface
+	rows := 0
+	for _, n := range ipam.Nodes {
+		if len(n.Loopbacks) == 0 || n.Loopbacks[0] != n.RouterID+"/32" {
+			t.Errorf("%s: loopbacks %v do not start with the router ID", n.Name, n.Loopbacks)
+		}
+		if n.Role == "server" && len(n.Loopbacks) != 2 {
+			t.Errorf("%s: anycast address missing from loopbacks", n.Name)
+		}
+		for _, i := range n.Interfaces {
+			ifaces[n.Name+"#"+i.Name] = i
+			rows++
+		}
+	}
+
+	// Every interface points at a peer that points back
+	for key, i := range ifaces {
+		peer, ok := ifaces[i.PeerNode+"#"+i.PeerInterface]
+		if !ok {
+			t.Errorf("%s: peer %s#%s not found", key, i.PeerNode, i.PeerInterface)
+			continue
+		}
+		if peer.PeerNode+"#"+peer.PeerInterface != key {
+			t.Errorf("%s: peer %s#%s points to %s#%s", key, i.PeerNode, i.PeerInterface, peer.PeerNode, peer.PeerInterface)
+		}
+		if i.MAC == "" || i.LLA == "" {
+			t.Errorf("%s: missing MAC or LLA", key)
+		}
+		numbered := NodeRole(key) == "tor" && NodeRole(i.PeerNode) == "leaf" ||
+			NodeRole(key) == "leaf" && NodeRole(i.PeerNode) == "tor"
+		if numbered != (i.Address != "") {
+			t.Errorf("%s: address %q, numbered %v", key, i.Address, numbered)
+		}
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ipam.csv")
+	if err := WriteIPAMCSV(path, ipam); err != nil {
+		t.Fatalf("WriteIPAMCSV failed: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != rows+1 {
+		t.Errorf("got %d CSV records, want %d", len(records), rows+1)
+	}
+
+	if err := WriteIPAMJSON(filepath.Join(dir, "ipam.json"), ipam); err != nil {
+		t.Fatalf("WriteIPAMJSON failed: %v", err)
+	}
+}
+
+func TestLessIfName(t *testing.T) {
+	names := []string{"lf2", "lf10", "bl0", "lf1"}
+	want := []string{"bl0", "lf1", "lf2", "lf10"}
+	sort.Slice(names, func(i, j int) bool { return lessIfName(names[i], names[j]) })
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got %v, want %v", names, want)
+		}
+	}
+}
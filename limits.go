@@ -0,0 +1,112 @@
+package main
+
+import "fmt"
+
+// Receive limit actions supported by BIRD.
+const (
+	ReceiveLimitWarn    = "warn"
+	ReceiveLimitBlock   = "block"
+	ReceiveLimitRestart = "restart"
+	ReceiveLimitDisable = "disable"
+)
+
+const (
+	// DefaultReceiveLimitHeadroom is the default headroom over the
+	// expected prefix count, in percent.
+	DefaultReceiveLimitHeadroom = 50
+
+	// minReceiveLimitHeadroom is the minimum headroom in prefixes, so that
+	// sessions expecting only a few prefixes are not at the limit.
+	minReceiveLimitHeadroom = 10
+
+	// serverPrefixes is the number of prefixes a server announces:
+	// its loopback and the anycast address.
+	serverPrefixes = 2
+)
+
+// ServerPrefixes returns the number of prefixes a server announces,
+// including its workload subnet if enabled.
+func (c Config) ServerPrefixes() int {
+	if c.Workloads() {
+		return serverPrefixes + 1
+	}
+	return serverPrefixes
+}
+
+// FabricPrefixes returns the number of IPv4 prefixes in the whole fabric:
+// one loopback per node, the server anycast address, the default route,
+// the workload subnets and the aggregates if enabled. This is the most any
+// session can receive.
+func (c Config) FabricPrefixes() int {
+	n := c.TotalNodes() + 2
+	if c.Workloads() {
+		n += c.TotalServers()
+	}
+	if c.Aggregate {
+		n += c.TotalToRs() + c.NumLeafPairs
+	}
+	return n
+}
+
+// PodPrefixes returns the number of prefixes originated within one leaf pair.
+func (c Config) PodPrefixes() int {
+	n := 2 + c.NumToRsPerLeafPair*c.RackPrefixes()
+	if c.Aggregate {
+		n++
+	}
+	return n
+}
+
+// RackPrefixes returns the number of prefixes a ToR announces to its leafs:
+// its loopback, its servers and their workload subnets, the anycast address
+// and the rack aggregate.
+// The anycast address is counted per rack; pod and fabric totals overcount
+// it slightly, which only adds headroom.
+func (c Config) RackPrefixes() int {
+	n := 2 + c.NumServersPerToR
+	if c.Workloads() {
+		n += c.NumServersPerToR
+	}
+	if c.Aggregate {
+		n++
+	}
+	return n
+}
+
+// EdgePrefixes returns the number of prefixes originated at the edge:
+// Border Leaf and Router loopbacks and the default route.
+func (c Config) EdgePrefixes() int {
+	return c.NumBorderLeafs + c.NumRouters + 1
+}
+
+// RouterPrefixes returns the number of prefixes a Border Leaf receives from
+// a Router: the edge prefixes and the external prefixes of that Router.
+// External prefixes stay at the Border Leafs, so other sessions do not
+// count them.
+func (c Config) RouterPrefixes(router string) int {
+	return c.EdgePrefixes() + len(c.Definition.ExternalPrefixes.Originated(router))
+}
+
+// ReceiveLimit returns the receive limit for a session expecting the
+// given number of prefixes.
+func (c Config) ReceiveLimit(expected int) int {
+	headroom := (expected*c.ReceiveLimitHeadroom + 99) / 100
+	if headroom < minReceiveLimitHeadroom {
+		headroom = minReceiveLimitHeadroom
+	}
+	return expected + headroom
+}
+
+// validateReceiveLimit checks the receive limit options.
+func (c Config) validateReceiveLimit() error {
+	switch c.ReceiveLimitAction {
+	case ReceiveLimitWarn, ReceiveLimitBlock, ReceiveLimitRestart, ReceiveLimitDisable:
+	default:
+		return fmt.Errorf("unknown receive limit action %q (must be %s, %s, %s or %s)",
+			c.ReceiveLimitAction, ReceiveLimitWarn, ReceiveLimitBlock, ReceiveLimitRestart, ReceiveLimitDisable)
+	}
+	if c.ReceiveLimitHeadroom < 0 {
+		return fmt.Errorf("-receive-limit-headroom must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Link tier codes, the top 3 bits of a link ID.
+const (
+	linkTierSpineLeaf = 1
+	linkTierSpineBL   = 2
+	linkTierLeafToR   = 3
+	linkTierToRServer = 4
+	linkTierBLRouter  = 5
+)
+
+// linkIDIndexBits is the width of the endpoint indexes in a link ID.
+const linkIDIndexBits = 28
+
+// linkIDField is an endpoint index in a link ID.
+type linkIDField struct {
+	name  string // What the field counts, for errors
+	index string // What one index denotes, for decoding
+	bits  int
+}
+
+// linkIDLayouts lists the endpoint index fields of each tier, most
+// significant first. Each layout uses 28 bits, between the 3-bit tier code
+// and the end bit. Indexes are stable when the topology grows: ToRs and
+// servers are numbered within their leaf pair and ToR, not globally.
+var linkIDLayouts = map[int][]linkIDField{
+	linkTierSpineLeaf: {{"spines", "spine", 12}, {"leaves", "leaf", 16}},
+	linkTierSpineBL:   {{"spines", "spine", 12}, {"border leaves", "border leaf", 16}},
+	linkTierLeafToR:   {{"leaves", "leaf", 15}, {"ToRs per leaf pair", "ToR", 13}},
+	linkTierToRServer: {{"leaf pairs", "leaf pair", 11}, {"ToRs per leaf pair", "ToR", 8}, {"servers per ToR", "server", 9}},
+	linkTierBLRouter:  {{"border leaves", "border leaf", 14}, {"routers", "router", 14}},
+}
+
+// LinkID returns the identifier of a link from its tier and endpoint
+// indexes. The lowest bit selects the end: the upper-tier end gets the
+// returned ID, the lower-tier end ID+1. Adding nodes does not change the
+// IDs of existing links.
+func LinkID(tier int, indexes ...int) uint32 {
+	id := uint32(tier)
+	for i, f := range linkIDLayouts[tier] {
+		id = id<<f.bits | uint32(indexes[i])
+	}
+	return id << 1
+}
+
+// DecodeLinkID returns the tier code and endpoint indexes of a link ID and
+// the end it identifies (0 = upper tier, 1 = lower tier). ok is false if the
+// tier code is not assigned.
+func DecodeLinkID(id uint32) (tier int, indexes []int, end int, ok bool) {
+	end = int(id & 1)
+	id >>= 1
+	tier = int(id >> linkIDIndexBits)
+	layout, ok := linkIDLayouts[tier]
+	if !ok {
+		return 0, nil, 0, false
+	}
+	indexes = make([]int, len(layout))
+	for i := len(layout) - 1; i >= 0; i-- {
+		indexes[i] = int(id & (1<<layout[i].bits - 1))
+		id >>= layout[i].bits
+	}
+	return tier, indexes, end, true
+}
+
+// DescribeLinkID returns a readable form of a link ID, e.g.
+// "tor-server link (leaf pair 0, ToR 1, server 0), lower end".
+func DescribeLinkID(id uint32) string {
+	tier, indexes, end, ok := DecodeLinkID(id)
+	if !ok {
+		return fmt.Sprintf("unassigned link ID %#x", id)
+	}
+	var fields []string
+	for i, f := range linkIDLayouts[tier] {
+		fields = append(fields, fmt.Sprintf("%s %d", f.index, indexes[i]))
+	}
+	ends := []string{"upper end", "lower end"}
+	return fmt.Sprintf("%s link (%s), %s", linkTiers[tier-1], strings.Join(fields, ", "), ends[end])
+}
+
+// linkIndexCounts returns the number of values each endpoint index of a
+// tier takes, in link ID field order.
+func (c Config) linkIndexCounts() map[int][]int {
+	return map[int][]int{
+		linkTierSpineLeaf: {c.NumSpines, c.NumLeafPairs * 2},
+		linkTierSpineBL:   {c.NumSpines, c.NumBorderLeafs},
+		linkTierLeafToR:   {c.NumLeafPairs * 2, c.NumToRsPerLeafPair},
+		linkTierToRServer: {c.NumLeafPairs, c.NumToRsPerLeafPair, c.NumServersPerToR},
+		linkTierBLRouter:  {c.NumBorderLeafs, c.NumRouters},
+	}
+}
+
+// validateLinkIDs checks that every endpoint index fits its link ID field.
+func (c Config) validateLinkIDs() error {
+	counts := c.linkIndexCounts()
+	for tier := linkTierSpineLeaf; tier <= linkTierBLRouter; tier++ {
+		for i, f := range linkIDLayouts[tier] {
+			if limit := 1 << f.bits; counts[tier][i] > limit {
+				return fmt.Errorf("link IDs support at most %d %s", limit, f.name)
+			}
+		}
+	}
+	return nil
+}
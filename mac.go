@@ -5,16 +5,20 @@ import (
 	"net"
 )
 
+// MaxFabricID is the highest fabric ID (the last byte of generated MACs).
+const MaxFabricID = 255
+
 // GenerateMAC generates a locally administered MAC address.
-// Format: 02:XX:XX:XX:XX:XX (U/L bit set for locally administered)
-func GenerateMAC(linkID uint32) net.HardwareAddr {
+// Format: 02:LL:LL:LL:LL:FF (U/L bit set for locally administered,
+// L = linkID, F = fabricID)
+func GenerateMAC(fabricID uint8, linkID uint32) net.HardwareAddr {
 	return net.HardwareAddr{
 		0x02, // Locally administered (U/L bit = 1)
 		byte(linkID >> 24),
 		byte(linkID >> 16),
 		byte(linkID >> 8),
 		byte(linkID),
-		0x00,
+		fabricID,
 	}
 }
 
@@ -46,8 +50,62 @@ func MACToLLA(mac net.HardwareAddr) net.IP {
 	return ip
 }
 
+// LLAToMAC inverts MACToLLA. It returns nil if ip is not a link-local
+// address with an EUI-64 interface identifier.
+func LLAToMAC(ip net.IP) net.HardwareAddr {
+	ip = ip.To16()
+	if ip == nil || ip.To4() != nil || !ip.IsLinkLocalUnicast() {
+		return nil
+	}
+	for _, b := range ip[2:8] {
+		if b != 0 {
+			return nil
+		}
+	}
+	if ip[11] != 0xFF || ip[12] != 0xFE {
+		return nil
+	}
+	return net.HardwareAddr{ip[8] ^ 0x02, ip[9], ip[10], ip[13], ip[14], ip[15]}
+}
+
 // FormatLLAWithInterface formats LLA with interface scope for BIRD.
 // Example: fe80::ff:fe00:1%eth0
 func FormatLLAWithInterface(ip net.IP, iface string) string {
 	return fmt.Sprintf("%s%%%s", ip, iface)
 }
+
+// MACFabricID returns the fabric ID: -fabric-id, or fabric_id of the
+// topology definition.
+func (c Config) MACFabricID() uint8 {
+	if c.FabricID != 0 {
+		return uint8(c.FabricID)
+	}
+	return uint8(c.Definition.FabricID)
+}
+
+// validateFabricID checks the fabric ID range.
+func (c Config) validateFabricID() error {
+	if c.FabricID < 0 || c.FabricID > MaxFabricID {
+		return fmt.Errorf("-fabric-id %d out of range (0-%d)", c.FabricID, MaxFabricID)
+	}
+	if c.Definition.FabricID < 0 || c.Definition.FabricID > MaxFabricID {
+		return fmt.Errorf("fabric_id %d out of range (0-%d)", c.Definition.FabricID, MaxFabricID)
+	}
+	return nil
+}
+
+// checkMACs returns an error if two interfaces got the same MAC, which
+// happens if the 32-bit link ID wraps around.
+func (t *Topology) checkMACs() error {
+	seen := make(map[string]string) // MAC -> node#interface
+	for name, ifaces := range t.peerLLAs {
+		for ifName, info := range ifaces {
+			key := name + "#" + ifName
+			if other, ok := seen[info.LocalMAC]; ok {
+				return fmt.Errorf("duplicate MAC %s on %s and %s", info.LocalMAC, other, key)
+			}
+			seen[info.LocalMAC] = key
+		}
+	}
+	return nil
+}
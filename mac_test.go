@@ -29,3 +29,114 @@ func TestMACToLLA(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateMAC(t *testing.T) {
+	tests := []struct {
+		fabricID uint8
+		linkID   uint32
+		expected string
+	}{
+		{0, 0, "02:00:00:00:00:00"},
+		{0, 0x01020304, "02:01:02:03:04:00"},
+		{7, 2, "02:00:00:00:02:07"},
+	}
+
+	for _, tt := range tests {
+		got := GenerateMAC(tt.fabricID, tt.linkID)
+		if got.String() != tt.expected {
+			t.Errorf("GenerateMAC(%d, %d) = %s, want %s", tt.fabricID, tt.linkID, got, tt.expected)
+		}
+	}
+}
+
+func TestFabricID(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Definition.FabricID = 3
+	if got := cfg.MACFabricID(); got != 3 {
+		t.Errorf("MACFabricID() = %d, want 3", got)
+	}
+	cfg.FabricID = 9
+	if got := cfg.MACFabricID(); got != 9 {
+		t.Errorf("MACFabricID() = %d, want 9 (flag overrides the definition)", got)
+	}
+
+	for _, id := range []int{-1, MaxFabricID + 1} {
+		c := DefaultConfig()
+		c.FabricID = id
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected error for fabric ID %d", id)
+		}
+	}
+
+	topo, _ := buildTestTopology(t, func(c *Config) { c.FabricID = 9 })
+	for name, ifaces := range topo.peerLLAs {
+		for ifName, info := range ifaces {
+			if info.LocalMAC[len(info.LocalMAC)-2:] != "09" {
+				t.Errorf("%s#%s: MAC %s does not end with the fabric ID", name, ifName, info.LocalMAC)
+			}
+		}
+	}
+
+	// A wrapped link ID reuses a MAC
+	info := topo.peerLLAs["spine0"]["lf0"]
+	topo.peerLLAs["spine1"]["lf0"] = info
+	if err := topo.checkMACs(); err == nil {
+		t.Errorf("expected error for duplicate MAC")
+	}
+}
+
+func TestLinkIDsStableUnderGrowth(t *testing.T) {
+	numbered := func(c *Config) {
+		c.NumberedLinks = NumberedAll
+		c.Definition.Addresses.Links6 = "fd00:0:0:ff::/64"
+	}
+	small, _ := buildTestTopology(t, numbered)
+	large, _ := buildTestTopology(t, func(c *Config) {
+		numbered(c)
+		c.NumSpines++
+		c.NumLeafPairs++
+		c.NumBorderLeafs++
+		c.NumRouters++
+	})
+
+	for name, ifaces := range small.peerLLAs {
+		for ifName, info := range ifaces {
+			grown, ok := large.peerLLAs[name][ifName]
+			if !ok {
+				t.Errorf("%s#%s missing after growth", name, ifName)
+				continue
+			}
+			if grown.LocalMAC != info.LocalMAC || grown.PeerLLA != info.PeerLLA {
+				t.Errorf("%s#%s changed: %s %s -> %s %s", name, ifName,
+					info.LocalMAC, info.PeerLLA, grown.LocalMAC, grown.PeerLLA)
+			}
+			if *grown.Numbered != *info.Numbered {
+				t.Errorf("%s#%s renumbered: %+v -> %+v", name, ifName, *info.Numbered, *grown.Numbered)
+			}
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.NumServersPerToR = 1 << 9
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate failed at the servers per ToR limit: %v", err)
+	}
+	cfg.NumServersPerToR++
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("expected error beyond the servers per ToR limit")
+	}
+}
+
+func TestLLAToMAC(t *testing.T) {
+	for _, s := range []string{"02:00:00:00:00:00", "02:80:00:04:01:00", "00:12:7f:eb:6b:40"} {
+		mac, _ := net.ParseMAC(s)
+		if got := LLAToMAC(MACToLLA(mac)); got.String() != s {
+			t.Errorf("LLAToMAC(MACToLLA(%s)) = %s", s, got)
+		}
+	}
+	for _, s := range []string{"fe80::1", "2001:db8::ff:fe00:1", "10.0.0.1"} {
+		if got := LLAToMAC(net.ParseIP(s)); got != nil {
+			t.Errorf("LLAToMAC(%s) = %s, want nil", s, got)
+		}
+	}
+}
@@ -4,31 +4,31 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"text/tabwriter"
 
 	"github.com/goccy/go-yaml"
 )
 
 func main() {
-	cfg := ParseFlags()
-
-	// Validate external network options
-	if cfg.ExternalNetwork && cfg.ExternalInterface == "" {
-		fmt.Fprintf(os.Stderr, "Error: -external-interface is required when -external-network is enabled\n")
-		os.Exit(1)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "drain":
+			runDrain(os.Args[2:])
+			return
+		case "whois":
+			runWhois(os.Args[2:])
+			return
+		case "path":
+			runPath(os.Args[2:])
+			return
+		}
 	}
 
-	// Load templates
-	templates, err := LoadTemplates(cfg.BirdTemplates)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading templates: %v\n", err)
-		os.Exit(1)
-	}
+	cfg := ParseFlags()
 
-	// Build topology
-	topo := NewTopology(cfg, templates)
-	spec, err := topo.Build()
+	topo, spec, err := buildTopology(cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error building topology: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -44,12 +44,77 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Write IPAM exports if requested
+	if err := writeIPAM(cfg, topo.IPAM()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing IPAM export: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Print ASN map if requested
+	if cfg.ASNReport {
+		printASNReport(topo.GetNodes())
+	}
+
 	// Print host setup commands if external network is enabled
 	if cfg.ExternalNetwork {
 		printHostSetupCommands(cfg)
 	}
 }
 
+// buildTopology loads the files referenced by cfg, validates it and builds
+// the topology. Warnings are printed to stderr.
+func buildTopology(cfg Config) (*Topology, Spec, error) {
+	// Load topology definition
+	if cfg.TopologyFile != "" {
+		def, err := LoadDefinition(cfg.TopologyFile)
+		if err != nil {
+			return nil, Spec{}, fmt.Errorf("loading topology definition: %w", err)
+		}
+		cfg.Definition = def
+	}
+
+	// Load BGP auth secrets
+	if cfg.BGPAuthFile != "" {
+		auth, err := LoadAuthFile(cfg.BGPAuthFile)
+		if err != nil {
+			return nil, Spec{}, fmt.Errorf("loading BGP auth file: %w", err)
+		}
+		cfg.Auth = auth
+	}
+
+	// Load ROAs
+	if cfg.RPKIROAFile != "" {
+		roas, err := LoadROAFile(cfg.RPKIROAFile)
+		if err != nil {
+			return nil, Spec{}, fmt.Errorf("loading ROA file: %w", err)
+		}
+		cfg.ROAs = roas
+	}
+
+	// Validate options
+	if err := cfg.Validate(); err != nil {
+		return nil, Spec{}, err
+	}
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+
+	// Load templates
+	templates, err := LoadTemplates(cfg.BirdTemplates)
+	if err != nil {
+		return nil, Spec{}, fmt.Errorf("loading templates: %w", err)
+	}
+
+	// Build topology
+	topo := NewTopology(cfg, templates)
+	spec, err := topo.Build()
+	if err != nil {
+		return nil, Spec{}, fmt.Errorf("building topology: %w", err)
+	}
+
+	return topo, spec, nil
+}
+
 func writeBirdConfigs(dir string, configs map[string]string) error {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", dir, err)
@@ -65,6 +130,20 @@ func writeBirdConfigs(dir string, configs map[string]string) error {
 	return nil
 }
 
+func writeIPAM(cfg Config, ipam IPAM) error {
+	if cfg.IPAMJSON != "" {
+		if err := WriteIPAMJSON(cfg.IPAMJSON, ipam); err != nil {
+			return err
+		}
+	}
+	if cfg.IPAMCSV != "" {
+		if err := WriteIPAMCSV(cfg.IPAMCSV, ipam); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func writeYAML(spec Spec) error {
 	data, err := yaml.MarshalWithOptions(spec, yaml.IndentSequence(true))
 	if err != nil {
@@ -97,3 +176,16 @@ func printHostSetupCommands(cfg Config) {
 	// IP forwarding
 	fmt.Fprintln(os.Stderr, "sudo sysctl -w net.ipv4.ip_forward=1")
 }
+
+func printASNReport(nodes []NodeInfo) {
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "# ASN map")
+	fmt.Fprintln(os.Stderr)
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tROLE\tASN")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", n.Name, n.Role, n.ASN)
+	}
+	w.Flush()
+}
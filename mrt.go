@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultMRTPeriod is the default interval between MRT table dumps in seconds.
+const DefaultMRTPeriod = 60
+
+// mrtBaseDir is the directory in the tinet volume that receives MRT files.
+const mrtBaseDir = "/tinet/mrt"
+
+// MRTDir returns the directory receiving the MRT files of a node.
+func MRTDir(name string) string {
+	return mrtBaseDir + "/" + name
+}
+
+// MRTPatterns returns the node globs given by -mrt-nodes.
+func (c Config) MRTPatterns() []string {
+	var patterns []string
+	for _, p := range strings.Split(c.MRTNodes, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// MRTEnabled reports whether a node writes MRT dumps.
+func (c Config) MRTEnabled(name string) bool {
+	for _, p := range c.MRTPatterns() {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateMRT checks the MRT node globs and dump period.
+func (c Config) validateMRT() error {
+	for _, p := range c.MRTPatterns() {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("-mrt-nodes: invalid pattern %q", p)
+		}
+	}
+	if c.MRTPeriod < 1 {
+		return fmt.Errorf("-mrt-period must be at least 1")
+	}
+	return nil
+}
+
+// checkMRTNodes returns an error if an -mrt-nodes glob matches no node.
+func (t *Topology) checkMRTNodes() error {
+	for _, p := range t.config.MRTPatterns() {
+		matched := false
+		for _, n := range t.nodes {
+			if ok, _ := path.Match(p, n.Name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("-mrt-nodes: %q does not match any node", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// NumberedAll selects numbered links on every tier.
+const NumberedAll = "all"
+
+// linkTiers are the tier keys of the fabric links.
+var linkTiers = []string{"spine-leaf", "spine-bl", "leaf-tor", "tor-server", "bl-router"}
+
+// NumberedTiers returns the tier keys given by -numbered-links.
+func (c Config) NumberedTiers() []string {
+	var tiers []string
+	for _, s := range strings.Split(c.NumberedLinks, ",") {
+		if s = strings.TrimSpace(s); s == NumberedAll {
+			return linkTiers
+		} else if s != "" {
+			tiers = append(tiers, s)
+		}
+	}
+	return tiers
+}
+
+// Numbered reports whether links of a tier are numbered.
+func (c Config) Numbered(tier string) bool {
+	return slices.Contains(c.NumberedTiers(), tier)
+}
+
+// linkTierBits is the width of the tier code in a link slot.
+const linkTierBits = 3
+
+// validateNumbered checks the -numbered-links tiers and that the link pools
+// hold a slot for every numbered link.
+func (c Config) validateNumbered(addrs AddressPlan) error {
+	for _, tier := range c.NumberedTiers() {
+		if !slices.Contains(linkTiers, tier) {
+			return fmt.Errorf("-numbered-links: unknown tier %q (must be %s or %s)",
+				tier, strings.Join(linkTiers, ", "), NumberedAll)
+		}
+	}
+	if len(c.NumberedTiers()) == 0 {
+		return nil
+	}
+
+	slotBits := addrs.linkSlotBits()
+	if slotBits < linkTierBits {
+		return fmt.Errorf("numbered links: address plan links %s is too small", addrs.Links)
+	}
+	counts := c.linkIndexCounts()
+	for _, key := range c.NumberedTiers() {
+		tier := slices.Index(linkTiers, key) + 1
+		for i, w := range linkSlotFields(slotBits, tier) {
+			if limit := 1 << w; counts[tier][i] > limit {
+				return fmt.Errorf("numbered links: address plan links %s numbers at most %d %s on %s links",
+					addrs.Links, limit, linkIDLayouts[tier][i].name, key)
+			}
+		}
+	}
+	if addrs.Links6.IsValid() && addrs.Links6.Bits() > 127-slotBits {
+		return fmt.Errorf("numbered links: address plan links6 %s must be at least a /%d to match links %s",
+			addrs.Links6, 127-slotBits, addrs.Links)
+	}
+	return nil
+}
+
+// linkSlotBits returns the width of a link slot: the number of /31s in the
+// links block, as a power of two.
+func (p AddressPlan) linkSlotBits() int {
+	return 31 - p.Links.Bits()
+}
+
+// linkSlotFields returns the widths of the endpoint indexes of a tier in a
+// link slot. The slot starts with the tier code; each endpoint index gets a
+// share of the remaining bits in proportion to its width in the link ID,
+// and the last one also takes the rounding remainder.
+func linkSlotFields(slotBits, tier int) []int {
+	avail := slotBits - linkTierBits
+	layout := linkIDLayouts[tier]
+	widths := make([]int, len(layout))
+	used := 0
+	for i, f := range layout {
+		widths[i] = avail * f.bits / linkIDIndexBits
+		used += widths[i]
+	}
+	widths[len(widths)-1] += avail - used
+	return widths
+}
+
+// linkSlot returns the position of a link in the link pools. Like the link
+// ID it is derived from, it only depends on the tier and the endpoint
+// indexes, so growing the fabric does not renumber existing links.
+func (p AddressPlan) linkSlot(linkID uint32) int {
+	tier, indexes, _, _ := DecodeLinkID(linkID)
+	slot := tier - 1
+	for i, w := range linkSlotFields(p.linkSlotBits(), tier) {
+		slot = slot<<w | indexes[i]
+	}
+	return slot
+}
+
+// linkAddrs holds the addresses of one numbered link.
+type linkAddrs struct {
+	Local, Peer   string // IPv4 addresses (without length)
+	Local6, Peer6 string // IPv6 addresses (empty without links6)
+}
+
+// numberLink returns the addresses of both ends of a link from its /31
+// (and /127) in the link pools. The first end gets the lower address.
+func (t *Topology) numberLink(linkID uint32) (end1, end2 linkAddrs) {
+	n := t.addrs.linkSlot(linkID)
+
+	end1.Local = formatIPv4(t.addrs.Links.offset(2 * n))
+	end1.Peer = formatIPv4(t.addrs.Links.offset(2*n + 1))
+	if p := t.addrs.Links6; p.IsValid() {
+		a := addIPv6(p.Addr(), uint64(2*n))
+		end1.Local6 = a.String()
+		end1.Peer6 = a.Next().String()
+	}
+	end2 = linkAddrs{Local: end1.Peer, Peer: end1.Local, Local6: end1.Peer6, Peer6: end1.Local6}
+	return end1, end2
+}
+
+// cmds returns the commands that configure the addresses on an interface.
+func (a linkAddrs) cmds(ifName string) []string {
+	cmds := []string{fmt.Sprintf("ip addr add %s/31 dev %s", a.Local, ifName)}
+	if a.Local6 != "" {
+		cmds = append(cmds, fmt.Sprintf("ip -6 addr add %s/127 dev %s", a.Local6, ifName))
+	}
+	return cmds
+}
+
+// addIPv6 returns the address n addresses after a.
+func addIPv6(a netip.Addr, n uint64) netip.Addr {
+	b := a.As16()
+	for i := 15; i >= 0 && n > 0; i-- {
+		sum := uint64(b[i]) + n&0xff
+		b[i] = byte(sum)
+		n = n>>8 + sum>>8
+	}
+	return netip.AddrFrom16(b)
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// DefaultMergePaths is the number of next hops BIRD merges into a kernel
+// route when merge paths has no limit.
+const DefaultMergePaths = 16
+
+// PathHop is a node on the equal-cost paths to a destination.
+type PathHop struct {
+	Hop   int // Distance from the source
+	Node  string
+	Role  string
+	Links int // Links to the destination on a shortest path
+	ECMP  int // Next hops installed in the kernel (Links, capped by merge paths)
+}
+
+// PathStep is a node of a path with the interfaces it is entered and left
+// through.
+type PathStep struct {
+	Node string
+	In   string // Empty at the source
+	Out  string // Empty at the destination
+}
+
+// Path is one of the equal-cost paths between two nodes.
+type Path struct {
+	Steps  []PathStep
+	ASPath []int // Nil in IGP routing modes
+}
+
+// PathReport holds the equal-cost paths between two nodes.
+type PathReport struct {
+	Src, Dst string
+	Length   int       // Hops from the source to the destination
+	Hops     []PathHop // Nodes forwarding on the paths, by distance from the source
+	Count    int       // Number of equal-cost paths
+	Paths    []Path    // The first paths, up to the requested maximum
+}
+
+// findNode returns the node named name, or the only node whose name
+// starts with name + "-" (e.g. "server12" for server12-as4200100012).
+func (t *Topology) findNode(name string) (NodeInfo, error) {
+	var matches []NodeInfo
+	for _, n := range t.nodes {
+		if n.Name == name {
+			return n, nil
+		}
+		if strings.HasPrefix(n.Name, name+"-") {
+			matches = append(matches, n)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return NodeInfo{}, fmt.Errorf("node %s not found in topology", name)
+	case 1:
+		return matches[0], nil
+	}
+	return NodeInfo{}, fmt.Errorf("node %s is ambiguous (%d nodes match)", name, len(matches))
+}
+
+// Paths returns the equal-cost paths from src to dst over the fabric
+// links, listing at most maxPaths of them. Paths have the fewest hops;
+// servers and routers do not forward between fabric links, so they only
+// appear as endpoints. With BGP, paths whose routes a node rejects because
+// the AS path holds its own ASN (e.g. between Border Leafs, which share an
+// ASN) are left out. Routing policies (TE, drain, backup egress) are not
+// taken into account.
+//
+// Paths are counted, and the links of each hop found, over the graph of
+// shortest paths without listing them, so only the returned paths are
+// built.
+func (t *Topology) Paths(src, dst string, maxPaths int) (PathReport, error) {
+	srcNode, err := t.findNode(src)
+	if err != nil {
+		return PathReport{}, err
+	}
+	dstNode, err := t.findNode(dst)
+	if err != nil {
+		return PathReport{}, err
+	}
+	if srcNode.Name == dstNode.Name {
+		return PathReport{}, fmt.Errorf("source and destination are both %s", srcNode.Name)
+	}
+
+	s := t.newPathSearch(dstNode.Name)
+	if _, ok := s.dist[srcNode.Name]; !ok {
+		return PathReport{}, fmt.Errorf("no path from %s to %s", srcNode.Name, dstNode.Name)
+	}
+	s.countSuffixes()
+
+	report := PathReport{
+		Src:    srcNode.Name,
+		Dst:    dstNode.Name,
+		Length: s.dist[srcNode.Name],
+	}
+	for _, n := range s.suffixes[srcNode.Name] {
+		report.Count += n
+	}
+	if report.Count == 0 {
+		return PathReport{}, fmt.Errorf("no path from %s to %s: BGP rejects the routes of nodes sharing an ASN",
+			srcNode.Name, dstNode.Name)
+	}
+
+	// Links used by each forwarding node, walking the hops from the source
+	// with the AS counts the upstream nodes still accept
+	accepted := map[string]map[string]bool{srcNode.Name: {s.unlimited(): true}}
+	layer := []string{srcNode.Name}
+	for hop := 0; hop < report.Length; hop++ {
+		var next []string
+		for _, node := range layer {
+			links := 0
+			for _, ifName := range s.nextHops(node) {
+				peer := t.peerLLAs[node][ifName].PeerNode
+				used := false
+				for r := range accepted[node] {
+					if r, ok := s.step(r, node); ok && s.completes(peer, r) {
+						if accepted[peer] == nil {
+							accepted[peer] = make(map[string]bool)
+							next = append(next, peer)
+						}
+						accepted[peer][r] = true
+						used = true
+					}
+				}
+				if used {
+					links++
+				}
+			}
+			report.Hops = append(report.Hops, PathHop{
+				Hop:   hop,
+				Node:  node,
+				Role:  s.nodes[node].Role,
+				Links: links,
+				ECMP:  t.ecmpWidth(node, links),
+			})
+		}
+		layer = next
+	}
+
+	// The first paths, in interface order
+	var walk func(steps []PathStep, r string)
+	walk = func(steps []PathStep, r string) {
+		last := steps[len(steps)-1]
+		if last.Node == dstNode.Name {
+			path := Path{Steps: append([]PathStep(nil), steps...)}
+			path.ASPath = t.pathASPath(path.Steps, s.nodes)
+			report.Paths = append(report.Paths, path)
+			return
+		}
+		r, ok := s.step(r, last.Node)
+		if !ok {
+			return
+		}
+		for _, ifName := range s.nextHops(last.Node) {
+			if len(report.Paths) >= maxPaths {
+				return
+			}
+			p := t.peerLLAs[last.Node][ifName]
+			if s.completes(p.PeerNode, r) {
+				steps[len(steps)-1].Out = ifName
+				walk(append(steps, PathStep{Node: p.PeerNode, In: p.PeerIf}), r)
+			}
+		}
+	}
+	if maxPaths > 0 {
+		walk([]PathStep{{Node: srcNode.Name}}, s.unlimited())
+	}
+
+	return report, nil
+}
+
+// pathSearch finds the shortest paths to a destination. A node accepts the
+// route of a path if its own ASN appears in the AS path it receives at most
+// as often as allow local as permits (ToRs sharing an ASN). Only ASNs that
+// several BGP speakers share can repeat on a path; their counts are kept as
+// one byte per ASN in a string, capped at limit:
+//
+//   - suffix counts: occurrences from a node to the destination
+//   - accepted counts: occurrences the upstream nodes of a path still accept
+//     from a node to the destination (limit meaning any number)
+type pathSearch struct {
+	t        *Topology
+	nodes    map[string]NodeInfo
+	dst      string
+	dist     map[string]int            // Hops to the destination
+	order    []string                  // Nodes by distance to the destination
+	shared   map[int]int               // Index of each shared ASN in the counts
+	limit    byte                      // One more than the largest allow local as
+	suffixes map[string]map[string]int // Node -> suffix counts -> accepted paths to the destination
+}
+
+// newPathSearch returns the search for paths to dst, with the distances of
+// the nodes to it.
+func (t *Topology) newPathSearch(dst string) *pathSearch {
+	s := &pathSearch{
+		t:        t,
+		nodes:    make(map[string]NodeInfo),
+		dst:      dst,
+		dist:     map[string]int{dst: 0},
+		shared:   make(map[int]int),
+		limit:    1,
+		suffixes: make(map[string]map[string]int),
+	}
+	for _, n := range t.nodes {
+		s.nodes[n.Name] = n
+	}
+
+	// Distances to the destination, walking back from it
+	queue := []string{dst}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		s.order = append(s.order, node)
+		if node != dst && !s.transit(node) {
+			continue
+		}
+		for _, p := range t.peerLLAs[node] {
+			if _, seen := s.dist[p.PeerNode]; !seen {
+				s.dist[p.PeerNode] = s.dist[node] + 1
+				queue = append(queue, p.PeerNode)
+			}
+		}
+	}
+
+	// ASNs of several BGP speakers on the paths
+	speakers := make(map[int]int)
+	for _, node := range s.order {
+		if s.speaks(node) {
+			speakers[s.nodes[node].ASN]++
+			s.limit = max(s.limit, byte(s.allow(node)+1))
+		}
+	}
+	for _, node := range s.order {
+		asn := s.nodes[node].ASN
+		if _, ok := s.shared[asn]; !ok && speakers[asn] > 1 {
+			s.shared[asn] = len(s.shared)
+		}
+	}
+	return s
+}
+
+// transit reports whether a node forwards between fabric links.
+func (s *pathSearch) transit(node string) bool {
+	return s.nodes[node].Role != "server" && s.nodes[node].Role != "router"
+}
+
+// speaks reports whether a node runs BGP and checks AS paths.
+func (s *pathSearch) speaks(node string) bool {
+	_, ok := s.t.birdConfigs[node]
+	return ok && s.t.config.RoutingMode == RoutingModeBGP
+}
+
+// allow returns how often a node accepts its own ASN in an AS path.
+func (s *pathSearch) allow(node string) int {
+	if s.nodes[node].Role == "tor" {
+		return s.t.torAllowLocalAS()
+	}
+	return 0
+}
+
+// nextHops returns the links of a node towards the destination on a
+// shortest path.
+func (s *pathSearch) nextHops(node string) []string {
+	var ifs []string
+	for ifName, p := range s.t.peerLLAs[node] {
+		if d, ok := s.dist[p.PeerNode]; ok && d == s.dist[node]-1 &&
+			(p.PeerNode == s.dst || s.transit(p.PeerNode)) {
+			ifs = append(ifs, ifName)
+		}
+	}
+	sort.Slice(ifs, func(i, j int) bool { return lessIfName(ifs[i], ifs[j]) })
+	return ifs
+}
+
+// countSuffixes counts the paths from every node to the destination whose
+// nodes accept the route, by suffix counts, from the destination outwards.
+func (s *pathSearch) countSuffixes() {
+	origin := []byte(s.zero())
+	if i, ok := s.sharedIndex(s.dst); ok {
+		origin[i] = 1
+	}
+	s.suffixes[s.dst] = map[string]int{string(origin): 1}
+
+	for _, node := range s.order[1:] {
+		counts := make(map[string]int)
+		i, shared := s.sharedIndex(node)
+		for _, ifName := range s.nextHops(node) {
+			for c, n := range s.suffixes[s.t.peerLLAs[node][ifName].PeerNode] {
+				if !shared {
+					counts[c] += n
+					continue
+				}
+				if int(c[i]) > s.allow(node) {
+					continue // The node rejects the route
+				}
+				b := []byte(c)
+				b[i] = min(b[i]+1, s.limit)
+				counts[string(b)] += n
+			}
+		}
+		s.suffixes[node] = counts
+	}
+}
+
+// sharedIndex returns the index of the ASN of a BGP speaker in the counts.
+func (s *pathSearch) sharedIndex(node string) (int, bool) {
+	if !s.speaks(node) {
+		return 0, false
+	}
+	i, ok := s.shared[s.nodes[node].ASN]
+	return i, ok
+}
+
+// zero returns counts with no occurrences.
+func (s *pathSearch) zero() string {
+	return string(make([]byte, len(s.shared)))
+}
+
+// unlimited returns the accepted counts of a path source.
+func (s *pathSearch) unlimited() string {
+	b := make([]byte, len(s.shared))
+	for i := range b {
+		b[i] = s.limit
+	}
+	return string(b)
+}
+
+// step returns the accepted counts after a node: its own occurrence is used
+// up, and it accepts its ASN at most allow more times. ok is false if an
+// upstream node rejects the route because of this node.
+func (s *pathSearch) step(accepted, node string) (string, bool) {
+	i, ok := s.sharedIndex(node)
+	if !ok {
+		return accepted, true
+	}
+	b := []byte(accepted)
+	if b[i] == 0 {
+		return "", false
+	}
+	if b[i] < s.limit {
+		b[i]--
+	}
+	b[i] = min(b[i], byte(s.allow(node)))
+	return string(b), true
+}
+
+// completes reports whether a path reaching node with the given accepted
+// counts continues to the destination.
+func (s *pathSearch) completes(node, accepted string) bool {
+	for c := range s.suffixes[node] {
+		ok := true
+		for i := 0; i < len(c) && ok; i++ {
+			ok = c[i] <= accepted[i]
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ecmpWidth returns the next hops a node installs for a route with links
+// equal-cost links: servers without BGP have a single default route, Babel
+// selects a single route, and BIRD merges at most the merge paths limit of
+// the role.
+func (t *Topology) ecmpWidth(node string, links int) int {
+	if _, ok := t.birdConfigs[node]; !ok || t.config.RoutingMode == RoutingModeBabel {
+		return min(links, 1)
+	}
+	limit := t.config.ECMP(NodeRole(node)).MergePaths
+	if limit == 0 {
+		limit = DefaultMergePaths
+	}
+	return min(links, limit)
+}
+
+// pathASPath returns the AS path of the route to the destination of a
+// path, as received by the source (or, for a server without BGP, by its
+// ToR). Servers without BGP do not appear: their ToR originates their
+// routes.
+func (t *Topology) pathASPath(steps []PathStep, nodes map[string]NodeInfo) []int {
+	if t.config.RoutingMode != RoutingModeBGP {
+		return nil
+	}
+	receiver := 0
+	if _, ok := t.birdConfigs[steps[0].Node]; !ok {
+		receiver = 1
+	}
+	asPath := []int{}
+	for _, s := range steps[min(receiver+1, len(steps)):] {
+		if _, ok := t.birdConfigs[s.Node]; ok {
+			asPath = append(asPath, nodes[s.Node].ASN)
+		}
+	}
+	return asPath
+}
+
+// String returns the path as node[out] -> [in]node[out] -> ... -> [in]node.
+func (p Path) String() string {
+	var parts []string
+	for _, s := range p.Steps {
+		part := s.Node
+		if s.In != "" {
+			part = "[" + s.In + "]" + part
+		}
+		if s.Out != "" {
+			part += "[" + s.Out + "]"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// runPath implements the path subcommand. It rebuilds the topology from
+// the same options and prints the equal-cost paths between two nodes.
+func runPath(args []string) {
+	fs := flag.NewFlagSet("path", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: clos-tinet path [flags] SRC DST")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "SRC and DST are node names, or their prefix before -as (e.g. server12).")
+		fmt.Fprintln(fs.Output(), "Topology flags must match the ones used to generate the lab.")
+		fs.PrintDefaults()
+	}
+
+	cfg := DefaultConfig()
+	cfg.RegisterFlags(fs)
+	maxPaths := fs.Int("max-paths", 32, "Maximum number of paths to list")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	topo, _, err := buildTopology(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := topo.Paths(fs.Arg(0), fs.Arg(1), *maxPaths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("# %s -> %s: %d equal-cost paths, %d hops\n",
+		report.Src, report.Dst, report.Count, report.Length)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOP\tNODE\tROLE\tLINKS\tECMP")
+	for _, h := range report.Hops {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\n", h.Hop, h.Node, h.Role, h.Links, h.ECMP)
+	}
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tAS PATH\tHOPS")
+	for i, p := range report.Paths {
+		asPath := "-"
+		if len(p.ASPath) > 0 {
+			var asns []string
+			for _, asn := range p.ASPath {
+				asns = append(asns, strconv.Itoa(asn))
+			}
+			asPath = strings.Join(asns, " ")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", i+1, asPath, p)
+	}
+	w.Flush()
+	if n := report.Count - len(report.Paths); n > 0 {
+		fmt.Printf("# %d more paths not listed (-max-paths)\n", n)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPaths(t *testing.T) {
+	fabric := func(c *Config) {
+		c.NumSpines = 4
+		c.NumLeafPairs = 2
+		c.NumBorderLeafs = 2
+	}
+	topo, _ := buildTestTopology(t, fabric)
+
+	// server0 -> tor0 -> 2 leaves -> 4 spines -> 2 Border Leafs -> router0
+	r, err := topo.Paths("server0", "router0", 4)
+	if err != nil {
+		t.Fatalf("Paths failed: %v", err)
+	}
+	if r.Count != 16 || r.Length != 5 || len(r.Paths) != 4 {
+		t.Errorf("got %d paths of %d hops (%d listed), want 16 of 5 (4 listed)", r.Count, r.Length, len(r.Paths))
+	}
+	widths := make(map[string]int)
+	for _, h := range r.Hops {
+		widths[h.Role] = h.ECMP
+	}
+	want := map[string]int{"server": 1, "tor": 2, "leaf": 4, "spine": 2, "bl": 1}
+	for role, w := range want {
+		if widths[role] != w {
+			t.Errorf("%s ECMP width = %d, want %d", role, widths[role], w)
+		}
+	}
+	p := r.Paths[0]
+	if p.Steps[0].Out != "tr0" || p.Steps[1].In != "sv0" || p.Steps[len(p.Steps)-1].Node != "router0" {
+		t.Errorf("unexpected path %s", p)
+	}
+	if got, want := p.ASPath[len(p.ASPath)-1], topo.asn.RouterASN(); len(p.ASPath) != 5 || got != want {
+		t.Errorf("AS path %v does not end at router0 AS %d", p.ASPath, want)
+	}
+
+	// Border Leafs share an ASN: their routes are rejected by each other
+	if _, err := topo.Paths("bl0", "bl1", 4); err == nil {
+		t.Error("path between Border Leafs sharing an ASN found")
+	}
+	if _, err := topo.Paths("leaf1", "spine0", 4); err == nil {
+		t.Error("ambiguous node name accepted")
+	}
+
+	// Merge paths caps the kernel next hops
+	topo, _ = buildTestTopology(t, func(c *Config) {
+		fabric(c)
+		c.ECMPMergePaths = 2
+	})
+	r, err = topo.Paths("server0", "router0", 4)
+	if err != nil {
+		t.Fatalf("Paths failed: %v", err)
+	}
+	if i := slices.IndexFunc(r.Hops, func(h PathHop) bool { return h.Role == "leaf" }); r.Hops[i].Links != 4 || r.Hops[i].ECMP != 2 {
+		t.Errorf("leaf links/ECMP = %d/%d, want 4/2", r.Hops[i].Links, r.Hops[i].ECMP)
+	}
+}
+
+func TestPathCounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(*Config)
+		src, dst string
+		maxPaths int
+		count    int
+	}{
+		// 2 leaves x 8 spines x 4 Border Leafs, none listed
+		{"count only", func(c *Config) {
+			c.NumSpines = 8
+			c.NumBorderLeafs = 4
+		}, "server0", "router0", 0, 64},
+		{"first paths", func(c *Config) {
+			c.NumSpines = 8
+			c.NumBorderLeafs = 4
+		}, "server0", "router0", 3, 64},
+		// ToRs sharing an ASN accept each other's routes with allow local as
+		{"ASN reuse", func(c *Config) {
+			c.NumLeafPairs = 2
+			c.ASNScheme = ASNSchemeReuse
+		}, "tor0", "tor2", 32, 8},
+		{"IGP", func(c *Config) {
+			c.NumBorderLeafs = 2
+			c.RoutingMode = RoutingModeOSPF
+		}, "bl0", "bl1", 32, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topo, _ := buildTestTopology(t, tt.mutate)
+			r, err := topo.Paths(tt.src, tt.dst, tt.maxPaths)
+			if err != nil {
+				t.Fatalf("Paths failed: %v", err)
+			}
+			if r.Count != tt.count || len(r.Paths) != min(tt.count, tt.maxPaths) {
+				t.Errorf("got %d paths (%d listed), want %d (%d listed)",
+					r.Count, len(r.Paths), tt.count, min(tt.count, tt.maxPaths))
+			}
+			for _, p := range r.Paths {
+				if len(p.Steps) != r.Length+1 || p.Steps[len(p.Steps)-1].Node != r.Dst {
+					t.Errorf("path %s does not reach %s in %d hops", p, r.Dst, r.Length)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// Rack modes.
+const (
+	// RackModeL3 routes to each server over its own access link.
+	RackModeL3 = "l3"
+
+	// RackModeL2 bridges the access links of a ToR into one rack segment
+	// with the ToR as gateway.
+	RackModeL2 = "l2"
+)
+
+// RackBridge is the ToR bridge holding the rack subnet in L2 racks.
+const RackBridge = "br0"
+
+// L2Racks reports whether ToRs bridge their servers into a rack segment.
+func (c Config) L2Racks() bool {
+	return c.RackMode == RackModeL2
+}
+
+// validateRackMode checks the rack mode and rejects options that need
+// routed access links.
+func (c Config) validateRackMode() error {
+	switch c.RackMode {
+	case RackModeL3:
+		return nil
+	case RackModeL2:
+	default:
+		return fmt.Errorf("unknown rack mode %q (must be %s or %s)",
+			c.RackMode, RackModeL3, RackModeL2)
+	}
+
+	l3Only := []struct {
+		set  bool
+		name string
+	}{
+		{c.Aggregate, "-aggregate"},
+		{c.Numbered("tor-server"), "-numbered-links tor-server"},
+		{len(c.Definition.Tenants) > 0, "tenants"},
+	}
+	for _, o := range l3Only {
+		if o.set {
+			return fmt.Errorf("%s requires -rack-mode %s", o.name, RackModeL3)
+		}
+	}
+	return nil
+}
+
+// AnycastGatewayMAC returns the MAC of the rack gateway, shared by every
+// ToR like an anycast gateway. Link ID 0 is never assigned to a link.
+func (c Config) AnycastGatewayMAC() net.HardwareAddr {
+	return GenerateMAC(c.MACFabricID(), 0)
+}
+
+// rackBridgeCmds returns the commands that bridge the server ports of a
+// ToR and configure the rack gateway.
+func (t *Topology) rackBridgeCmds(gateway string) []Command {
+	cmds := []Command{
+		{Cmd: fmt.Sprintf("ip link add %s type bridge", RackBridge)},
+		{Cmd: fmt.Sprintf("ip link set dev %s address %s", RackBridge, t.config.AnycastGatewayMAC())},
+		{Cmd: fmt.Sprintf("ip addr add %s dev %s", gateway, RackBridge)},
+	}
+	for srvIdx := 0; srvIdx < t.config.NumServersPerToR; srvIdx++ {
+		cmds = append(cmds, Command{Cmd: fmt.Sprintf("ip link set dev sv%d master %s", srvIdx, RackBridge)})
+	}
+	return append(cmds, Command{Cmd: fmt.Sprintf("ip link set dev %s up", RackBridge)})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// DefaultRTRPort is the IANA port of the RPKI-to-Router protocol (RFC 8210).
+const DefaultRTRPort = 323
+
+// ROA is a Route Origin Authorization: prefixes up to MaxLength covered by
+// Prefix may be originated by ASN.
+type ROA struct {
+	Prefix    string `yaml:"prefix"`
+	MaxLength int    `yaml:"maxLength"` // 0 = prefix length
+	ASN       ROAASN `yaml:"asn"`
+}
+
+// ROAASN is an origin ASN written either as a number or as "AS64500",
+// the form used by validator JSON exports.
+type ROAASN uint32
+
+// UnmarshalYAML accepts both forms.
+func (a *ROAASN) UnmarshalYAML(b []byte) error {
+	s := strings.Trim(strings.TrimSpace(string(b)), `"'`)
+	s = strings.TrimPrefix(strings.ToUpper(s), "AS")
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid ASN %s", b)
+	}
+	*a = ROAASN(n)
+	return nil
+}
+
+// ROAFile holds the valid origins loaded from the file given by
+// -rpki-roa-file. The format is the JSON export of common RPKI validators
+// (e.g. rpki-client, Routinator), which is also valid YAML.
+type ROAFile struct {
+	ROAs []ROA `yaml:"roas"`
+}
+
+// LoadROAFile loads ROAs from a JSON or YAML file. Unknown fields such as
+// the trust anchor are ignored.
+func LoadROAFile(path string) (ROAFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ROAFile{}, err
+	}
+
+	var f ROAFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return ROAFile{}, err
+	}
+
+	return f, nil
+}
+
+// RPKI holds the origin validation settings of Border Leafs and Routers.
+type RPKI struct {
+	ROAs      []ROA  // Static IPv4 ROAs
+	CacheHost string // RTR cache address (empty if none)
+	CachePort int
+}
+
+// NewRPKI returns the RPKI settings for a configuration. IPv6 ROAs are
+// dropped because the fabric is IPv4-only.
+func NewRPKI(c Config) (RPKI, error) {
+	var r RPKI
+	for _, roa := range c.ROAs.ROAs {
+		p, err := netip.ParsePrefix(roa.Prefix)
+		if err != nil {
+			return RPKI{}, fmt.Errorf("ROA %s: %w", roa.Prefix, err)
+		}
+		if !p.Addr().Is4() {
+			continue
+		}
+		if roa.MaxLength == 0 {
+			roa.MaxLength = p.Bits()
+		}
+		if roa.MaxLength < p.Bits() || roa.MaxLength > 32 {
+			return RPKI{}, fmt.Errorf("ROA %s: max length %d out of range", roa.Prefix, roa.MaxLength)
+		}
+		roa.Prefix = p.Masked().String()
+		r.ROAs = append(r.ROAs, roa)
+	}
+
+	if c.RPKICache != "" {
+		host, port, err := splitHostPort(c.RPKICache, DefaultRTRPort)
+		if err != nil {
+			return RPKI{}, fmt.Errorf("-rpki-cache: %w", err)
+		}
+		r.CacheHost, r.CachePort = host, port
+	}
+
+	return r, nil
+}
+
+// Enabled reports whether origin validation is configured.
+func (r RPKI) Enabled() bool {
+	return len(r.ROAs) > 0 || r.CacheHost != ""
+}
+
+// splitHostPort splits "host[:port]", using defaultPort when no port is given.
+func splitHostPort(s string, defaultPort int) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		// No port (or a bare IPv6 address)
+		return strings.Trim(s, "[]"), defaultPort, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port %q", portStr)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("missing host in %q", s)
+	}
+	return host, port, nil
+}
+
+// ExternalPrefix is a prefix originated by a Router on behalf of the
+// external network, used to exercise origin validation offline.
+type ExternalPrefix struct {
+	Router string `yaml:"router"` // Originating Router (e.g. router0)
+	Prefix string `yaml:"prefix"` // IPv4 prefix outside the address plan
+	Origin uint32 `yaml:"origin"` // Origin ASN prepended to the path (0 = the Router's ASN)
+}
+
+// ExternalPrefixes is the list of external prefixes in the topology definition.
+type ExternalPrefixes []ExternalPrefix
+
+// Validate checks that each prefix is valid, outside the fabric address
+// plan and originated by an existing Router.
+func (e ExternalPrefixes) Validate(c Config, addrs AddressPlan) error {
+	for _, x := range e {
+		var idx int
+		if !scanIndex(x.Router, "router%d", &idx) || idx >= c.NumRouters {
+			return fmt.Errorf("external prefix %s: router %s does not exist", x.Prefix, x.Router)
+		}
+		p, err := netip.ParsePrefix(x.Prefix)
+		if err != nil {
+			return fmt.Errorf("external prefix %s: %w", x.Prefix, err)
+		}
+		if !p.Addr().Is4() || p != p.Masked() {
+			return fmt.Errorf("external prefix %s: must be a masked IPv4 prefix", x.Prefix)
+		}
+		if b, ok := addrs.Overlapping(p); ok {
+			return fmt.Errorf("external prefix %s overlaps the fabric address block %s", x.Prefix, b)
+		}
+	}
+	return nil
+}
+
+// Originated returns the external prefixes originated by a Router.
+func (e ExternalPrefixes) Originated(router string) ExternalPrefixes {
+	var out ExternalPrefixes
+	for _, x := range e {
+		if x.Router == router {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// PrefixSet returns the prefixes as the contents of a BIRD prefix set
+// (e.g. "192.0.2.0/24, 198.51.100.0/24"), or "" if there are none.
+func (e ExternalPrefixes) PrefixSet() string {
+	var prefixes []string
+	for _, x := range e {
+		prefixes = append(prefixes, x.Prefix)
+	}
+	return strings.Join(prefixes, ", ")
+}
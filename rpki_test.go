@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestRPKI(t *testing.T) {
+	// Validator JSON export, with ASNs in both forms and an IPv6 ROA
+	export := `{"roas": [
+		{"asn": "AS64500", "prefix": "192.0.2.0/24", "maxLength": 24, "ta": "test"},
+		{"asn": 64501, "prefix": "198.51.100.0/22", "ta": "test"},
+		{"asn": "AS64502", "prefix": "2001:db8::/32", "maxLength": 48, "ta": "test"}
+	]}`
+	var roas ROAFile
+	if err := yaml.Unmarshal([]byte(export), &roas); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	cfg := func(c *Config) {
+		c.ROAs = roas
+		c.RPKICache = "192.0.2.10"
+		c.Definition.ExternalPrefixes = ExternalPrefixes{
+			{Router: "router0", Prefix: "192.0.2.0/24", Origin: 64500},
+			{Router: "router0", Prefix: "203.0.113.0/24"},
+		}
+	}
+	topo, _ := buildTestTopology(t, cfg)
+	configs := topo.GetBirdConfigs()
+
+	for _, name := range []string{"bl0", "router0"} {
+		for _, want := range []string{
+			"route 192.0.2.0/24 max 24 as 64500;",
+			"route 198.51.100.0/22 max 22 as 64501;",
+			`remote "192.0.2.10" port 323;`,
+		} {
+			if !strings.Contains(configs[name], want) {
+				t.Errorf("%s config missing %q", name, want)
+			}
+		}
+		if strings.Contains(configs[name], "2001:db8::/32") {
+			t.Errorf("%s config contains an IPv6 ROA", name)
+		}
+	}
+	if strings.Contains(configs["spine0"], "roa") {
+		t.Errorf("spine0 should not validate origins")
+	}
+
+	router := configs["router0"]
+	for _, want := range []string{
+		"route 192.0.2.0/24 blackhole { bgp_path.prepend(64500); };",
+		"route 203.0.113.0/24 blackhole;",
+	} {
+		if !strings.Contains(router, want) {
+			t.Errorf("router0 config missing %q", want)
+		}
+	}
+
+	// Invalid origins are rejected at the edge; external prefixes stay there
+	imp, _ := filterBody(configs["bl0"], "bl_import_from_router")
+	if !strings.Contains(imp, "if roa_check(roa_v4, net, bgp_path.last) = ROA_INVALID then reject;") {
+		t.Errorf("bl_import_from_router does not validate origins")
+	}
+	if !strings.Contains(imp, "if net ~ [ 192.0.2.0/24, 203.0.113.0/24 ] then accept;") {
+		t.Errorf("bl_import_from_router does not accept external prefixes")
+	}
+	if exp, _ := filterBody(configs["bl0"], "bl_export_to_spine"); strings.Contains(exp, "192.0.2.0/24") {
+		t.Errorf("bl_export_to_spine exports external prefixes")
+	}
+
+	with := func(mutate func(*Config)) func(*Config) {
+		return func(c *Config) {
+			cfg(c)
+			c.Definition.ExternalPrefixes = ExternalPrefixes{{Router: "router0", Prefix: "192.0.2.0/24"}}
+			mutate(c)
+		}
+	}
+	expectInvalid(t, map[string]func(*Config){
+		"bad cache port":           with(func(c *Config) { c.RPKICache = "cache:99999" }),
+		"max length below prefix":  with(func(c *Config) { c.ROAs.ROAs = []ROA{{Prefix: "192.0.2.0/24", MaxLength: 16, ASN: 1}} }),
+		"external prefix internal": with(func(c *Config) { c.Definition.ExternalPrefixes[0].Prefix = "10.0.1.0/24" }),
+		"unknown router":           with(func(c *Config) { c.Definition.ExternalPrefixes[0].Router = "router1" }),
+	})
+}
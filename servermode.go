@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Server modes.
+const (
+	// ServerModeBGP runs BIRD on every server, peering with its ToR.
+	ServerModeBGP = "bgp"
+
+	// ServerModeStatic leaves servers without a routing daemon: they use a
+	// static default route via their ToR, which originates their routes.
+	ServerModeStatic = "static"
+)
+
+// StaticServers reports whether servers run without BGP. Servers in L2
+// racks never do.
+func (c Config) StaticServers() bool {
+	return c.ServerMode == ServerModeStatic || c.L2Racks()
+}
+
+// validateServerMode checks the server mode and rejects options that need
+// BGP on servers.
+func (c Config) validateServerMode() error {
+	switch c.ServerMode {
+	case ServerModeBGP:
+		return nil
+	case ServerModeStatic:
+	default:
+		return fmt.Errorf("unknown server mode %q (must be %s or %s)",
+			c.ServerMode, ServerModeBGP, ServerModeStatic)
+	}
+	if len(c.Definition.Tenants) > 0 {
+		return fmt.Errorf("tenants require -server-mode %s", ServerModeBGP)
+	}
+	return nil
+}
+
+// StaticRoute is a route of a BIRD static protocol.
+type StaticRoute struct {
+	Prefix string
+	Via    string // BIRD next hop: "interface", address or address%interface onlink
+}
+
+// staticServerRoutes returns the routes a ToR originates for its servers:
+// each server loopback and workload subnet, via the access link. In L2
+// racks the rack subnet covers the servers, and workload subnets are
+// routed via the server address on it.
+func (t *Topology) staticServerRoutes(name string, pairIdx, torIdx, globalToRIdx int) []StaticRoute {
+	var routes []StaticRoute
+	for srvIdx := 0; srvIdx < t.config.NumServersPerToR; srvIdx++ {
+		globalSrvIdx := globalToRIdx*t.config.NumServersPerToR + srvIdx
+		myIf := fmt.Sprintf("sv%d", srvIdx)
+		routerID := t.serverRouterID(pairIdx, torIdx, srvIdx, globalSrvIdx)
+
+		if t.config.L2Racks() {
+			if t.config.Workloads() {
+				routes = append(routes, StaticRoute{
+					Prefix: t.addrs.WorkloadSubnet(globalSrvIdx, t.config.WorkloadPrefixLen),
+					Via:    routerID,
+				})
+			}
+			continue
+		}
+
+		// Unnumbered links have no gateway address: the loopback is on-link
+		// and the workload subnet is reached via the loopback.
+		via := fmt.Sprintf("%q", myIf)
+		gateway := fmt.Sprintf("%s%%%s onlink", routerID, myIf)
+		if num := t.peerLLAs[name][myIf].Numbered; num != nil {
+			via, gateway = num.Peer, num.Peer
+		}
+
+		routes = append(routes, StaticRoute{Prefix: routerID + "/32", Via: via})
+		if t.config.Workloads() {
+			routes = append(routes, StaticRoute{
+				Prefix: t.addrs.WorkloadSubnet(globalSrvIdx, t.config.WorkloadPrefixLen),
+				Via:    gateway,
+			})
+		}
+	}
+	return routes
+}
+
+// addStaticServerConfig adds a server without BIRD: its loopback (or its
+// address on the rack subnet in L2 racks), its workload bridge and a default
+// route via its ToR.
+func (t *Topology) addStaticServerConfig(name string, data TemplateData, torRouterID string) {
+	loopbacks := []string{}
+	var cmds []Command
+	if data.RackSubnet == "" {
+		loopbacks = append(loopbacks, data.RouterID+"/32")
+		cmds = append(cmds, Command{Cmd: fmt.Sprintf("ip addr add %s/32 dev lo", data.RouterID)})
+	}
+	var workload string
+	if data.Workload != "" {
+		workload = subnetGateway(data.Workload)
+	}
+	t.nodes = append(t.nodes, NodeInfo{
+		Name:      name,
+		Role:      "server",
+		ASN:       data.ASN,
+		RouterID:  data.RouterID,
+		Loopbacks: loopbacks,
+		Workload:  workload,
+	})
+
+	if workload != "" {
+		cmds = append(cmds, workloadCmds(workload)...)
+	}
+	for _, macCmd := range t.macCmds[name] {
+		cmds = append(cmds, Command{Cmd: macCmd})
+	}
+
+	// On unnumbered links the ToR loopback is on-link, like the server
+	// loopback is on the ToR side.
+	route := fmt.Sprintf("ip route add default via %s dev tr0 onlink", torRouterID)
+	if num := t.peerLLAs[name]["tr0"].Numbered; num != nil {
+		route = fmt.Sprintf("ip route add default via %s dev tr0", num.Peer)
+	}
+	if rack := data.RackSubnet; rack != "" {
+		info := t.peerLLAs[name]["tr0"]
+		info.Address = fmt.Sprintf("%s/%d", data.RouterID, netip.MustParsePrefix(rack).Bits())
+		t.peerLLAs[name]["tr0"] = info
+		cmds = append(cmds, Command{Cmd: fmt.Sprintf("ip addr add %s dev tr0", info.Address)})
+		gateway := netip.MustParsePrefix(subnetGateway(rack)).Addr()
+		route = fmt.Sprintf("ip route add default via %s", gateway)
+	}
+	cmds = append(cmds,
+		Command{Cmd: "sysctl -w net.ipv4.ip_forward=1"},
+		Command{Cmd: "sysctl -w net.ipv6.conf.all.forwarding=1"},
+		Command{Cmd: route},
+	)
+
+	t.nodeConfigs = append(t.nodeConfigs, NodeConfig{Name: name, Cmds: cmds})
+}
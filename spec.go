@@ -56,14 +56,30 @@ type Command struct {
 
 // Neighbor represents a BGP neighbor.
 type Neighbor struct {
-	Name         string
-	Interface    string
-	PeerASN      int    // Peer's AS number
-	PeerLLA      string // Peer's link-local address with interface scope (e.g., fe80::1%eth0)
-	LocalLLA     string // Local link-local address (without interface scope)
-	ImportFilter string
-	ExportFilter string
-	MaxPrefix    int
+	Name             string
+	Interface        string
+	VRF              string // Tenant VRF of the session (empty = default table)
+	PeerASN          int    // Peer's AS number
+	PeerLLA          string // Peer's link-local address with interface scope (e.g., fe80::1%eth0)
+	LocalLLA         string // Local link-local address (without interface scope)
+	Numbered         bool   // Session runs on PeerAddress/LocalAddress instead of the LLAs
+	PeerAddress      string // Peer's /31 address (numbered links)
+	LocalAddress     string // Local /31 address (numbered links)
+	ImportFilter     string
+	ExportFilter     string
+	BaseImportFilter string   // Role filter wrapped by ImportFilter when ImportTE is active
+	BaseExportFilter string   // Role filter wrapped by ExportFilter when ExportTE is active
+	ImportTE         TEAction // Traffic engineering on routes received from this neighbor
+	ExportTE         TEAction // Traffic engineering on routes sent to this neighbor
+	MaxPrefix        int
+	MaxPrefixAction  string // Receive limit action: warn, block, restart or disable
+	AllowLocalAS     int    // Times the local ASN is accepted in received AS paths (0 = disabled)
+	Password         string // TCP-MD5 password (empty = no authentication)
+	TTLSecurity      bool   // Enable GTSM (RFC 5082)
+	LocalPref        int    // Local preference for the default route from this neighbor (0 = unchanged)
+
+	TimerProfile        // BFD and BGP timers
+	Profile      string // Timer profile name
 }
 
 // ExternalRouterIP returns the external network IP for a router by index.
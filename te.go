@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"path"
+)
+
+// Traffic engineering policy directions.
+const (
+	TEExport = "export"
+	TEImport = "import"
+)
+
+// TEPolicy is a traffic engineering policy in the topology definition.
+// It applies to every BGP session whose local node matches Node and whose
+// peer matches Peer (shell glob patterns, e.g. "spine3" and "*-as4200001005").
+type TEPolicy struct {
+	Node      string `yaml:"node"`
+	Peer      string `yaml:"peer"`
+	Direction string `yaml:"direction"`  // export (default) or import
+	Prepend   int    `yaml:"prepend"`    // Times to prepend LOCAL_AS (export only)
+	MED       int    `yaml:"med"`        // MED to set
+	LocalPref int    `yaml:"local_pref"` // Local preference to set (import only)
+}
+
+// TEAction holds the route attribute changes applied by a per-neighbor filter.
+type TEAction struct {
+	Prepend          int
+	MED              int
+	LocalPref        int
+	GracefulShutdown bool // Add the GRACEFUL_SHUTDOWN community (RFC 8326)
+}
+
+// Active reports whether the action changes anything.
+func (a TEAction) Active() bool {
+	return a.Prepend > 0 || a.MED > 0 || a.LocalPref > 0 || a.GracefulShutdown
+}
+
+// merge overrides the fields of a that are set in b.
+func (a TEAction) merge(b TEAction) TEAction {
+	if b.Prepend > 0 {
+		a.Prepend = b.Prepend
+	}
+	if b.MED > 0 {
+		a.MED = b.MED
+	}
+	if b.LocalPref > 0 {
+		a.LocalPref = b.LocalPref
+	}
+	a.GracefulShutdown = a.GracefulShutdown || b.GracefulShutdown
+	return a
+}
+
+// direction returns the policy direction, defaulting to export.
+func (p TEPolicy) direction() string {
+	if p.Direction == "" {
+		return TEExport
+	}
+	return p.Direction
+}
+
+// action returns the attribute changes of the policy.
+func (p TEPolicy) action() TEAction {
+	return TEAction{Prepend: p.Prepend, MED: p.MED, LocalPref: p.LocalPref}
+}
+
+// matches reports whether the policy applies to a session.
+func (p TEPolicy) matches(local, peer string) bool {
+	nodeOK, _ := path.Match(p.Node, local)
+	peerOK, _ := path.Match(p.Peer, peer)
+	return nodeOK && peerOK
+}
+
+// Validate checks the patterns and that the actions fit the direction.
+func (p TEPolicy) Validate() error {
+	for _, pattern := range []string{p.Node, p.Peer} {
+		if pattern == "" {
+			return fmt.Errorf("node and peer are required")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if p.Prepend < 0 || p.MED < 0 || p.LocalPref < 0 {
+		return fmt.Errorf("prepend, med and local_pref must not be negative")
+	}
+	if !p.action().Active() {
+		return fmt.Errorf("no prepend, med or local_pref set")
+	}
+
+	switch p.direction() {
+	case TEExport:
+		if p.LocalPref > 0 {
+			return fmt.Errorf("local_pref is not sent to eBGP peers; use direction import")
+		}
+	case TEImport:
+		if p.Prepend > 0 {
+			return fmt.Errorf("prepend applies to exported routes; use direction export")
+		}
+	default:
+		return fmt.Errorf("unknown direction %q (must be %s or %s)", p.Direction, TEExport, TEImport)
+	}
+	return nil
+}
+
+// TEPolicies is the ordered list of traffic engineering policies.
+// When several policies match a session, later ones override earlier ones.
+type TEPolicies []TEPolicy
+
+// Validate checks every policy.
+func (ps TEPolicies) Validate() error {
+	for i, p := range ps {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("policy %d (%s -> %s): %w", i, p.Node, p.Peer, err)
+		}
+	}
+	return nil
+}
+
+// Actions returns the import and export actions for a session and the
+// indexes of the policies that matched.
+func (ps TEPolicies) Actions(local, peer string) (imp, exp TEAction, matched []int) {
+	for i, p := range ps {
+		if !p.matches(local, peer) {
+			continue
+		}
+		if p.direction() == TEImport {
+			imp = imp.merge(p.action())
+		} else {
+			exp = exp.merge(p.action())
+		}
+		matched = append(matched, i)
+	}
+	return imp, exp, matched
+}
@@ -9,7 +9,9 @@ import (
 )
 
 // Templates holds BIRD configuration templates for each role.
+// Common holds {{ define }} blocks shared by all roles.
 type Templates struct {
+	Common string `yaml:"common"`
 	Spine  string `yaml:"spine"`
 	Leaf   string `yaml:"leaf"`
 	BL     string `yaml:"bl"`
@@ -20,9 +22,39 @@ type Templates struct {
 
 // TemplateData holds data for template rendering.
 type TemplateData struct {
-	RouterID  string
-	ASN       int
-	Neighbors []Neighbor
+	Name          string // Node name
+	RouterID      string
+	ASN           int
+	Neighbors     []Neighbor
+	RoutingMode   string    // "bgp", "ospf" or "babel"
+	RoutingPolicy string    // "prefix" or "community"
+	Community     Community // Large community values for originated routes
+
+	Addresses AddressPlan // Address blocks for filter prefix sets
+	Workload  string      // Workload subnet of this server (empty if none)
+	Workloads string      // Workload subnets as a BIRD prefix pattern (empty if none)
+
+	Aggregate            string // Aggregate prefix originated by this node (empty if none)
+	AggregateSummaryOnly bool   // Suppress more-specifics covered by Aggregate
+
+	ServerRoutes []StaticRoute // Routes originated for servers without BGP (ToR, -server-mode static)
+	RackSubnet   string        // Rack subnet on the ToR bridge (-rack-mode l2, empty otherwise)
+
+	SpineASNs string // Spine ASN range (e.g. "4200000100..4200000107") when Spines have unique ASNs
+
+	EgressPrepend int // Times to prepend LOCAL_AS to the exported default route (backup egress)
+	EgressMED     int // MED set on the exported default route (backup egress, 0 = none)
+
+	RPKI             RPKI             // Origin validation (Border Leafs and Routers)
+	ExternalRoutes   ExternalPrefixes // External prefixes originated by this Router
+	ExternalPrefixes string           // All external prefixes as a BIRD prefix set (empty if none)
+
+	Tenants []TenantData // Tenant VRFs on this node
+
+	MergePaths int // Kernel merge paths limit (0 = BIRD default)
+
+	MRTDir    string // Directory for MRT dumps (empty if disabled)
+	MRTPeriod int    // Seconds between table dumps
 }
 
 // LoadTemplates loads templates from a YAML file.
@@ -60,10 +92,24 @@ func (t *Templates) Render(role string, data TemplateData) (string, error) {
 		tmplStr = ""
 	}
 
-	tmpl, err := template.New(role).Parse(tmplStr)
+	// include executes a template chosen at render time, so that
+	// per-neighbor filters can wrap the role filter they replace.
+	var tmpl *template.Template
+	funcs := template.FuncMap{
+		"include": func(name string, data any) (string, error) {
+			var buf bytes.Buffer
+			err := tmpl.ExecuteTemplate(&buf, name, data)
+			return buf.String(), err
+		},
+	}
+
+	tmpl, err := template.New(role).Funcs(funcs).Parse(t.Common)
 	if err != nil {
 		return "", err
 	}
+	if _, err := tmpl.Parse(tmplStr); err != nil {
+		return "", err
+	}
 
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// TenantTableBase is added to the tenant ID to get the kernel routing table
+// of the tenant VRF, keeping clear of the reserved tables 253-255.
+const TenantTableBase = 1000
+
+// tenantName matches tenant names usable as VRF device names (with a "-lo"
+// suffix for the tenant loopback) and in BIRD protocol names.
+var tenantName = regexp.MustCompile(`^[a-z][a-z0-9]{0,11}$`)
+
+// Tenant is a VRF that spans its servers and the ToRs, Leafs and Spines
+// between them. It is carried on VLAN subinterfaces of the fabric links
+// with one BGP session per tenant and link (VRF-lite).
+type Tenant struct {
+	Name    string   `yaml:"name"`    // VRF device name
+	ID      int      `yaml:"id"`      // VLAN ID (kernel table TenantTableBase+ID)
+	Prefix  string   `yaml:"prefix"`  // IPv4 prefix for server tenant addresses
+	Servers []string `yaml:"servers"` // Globs matching member servers
+}
+
+// Tenants is the list of tenants in the topology definition.
+type Tenants []Tenant
+
+// Validate checks tenant names, IDs and prefixes. Prefixes must not
+// overlap the fabric address plan.
+func (ts Tenants) Validate(addrs AddressPlan) error {
+	names := make(map[string]bool)
+	ids := make(map[int]bool)
+	var prefixes []netip.Prefix
+	for _, tn := range ts {
+		if !tenantName.MatchString(tn.Name) {
+			return fmt.Errorf("tenant %q: name must be 1-12 lowercase letters or digits, starting with a letter", tn.Name)
+		}
+		if names[tn.Name] {
+			return fmt.Errorf("duplicate tenant %s", tn.Name)
+		}
+		names[tn.Name] = true
+
+		if tn.ID < 1 || tn.ID > 4094 {
+			return fmt.Errorf("tenant %s: id %d out of range (1-4094)", tn.Name, tn.ID)
+		}
+		if ids[tn.ID] {
+			return fmt.Errorf("tenant %s: duplicate id %d", tn.Name, tn.ID)
+		}
+		ids[tn.ID] = true
+
+		p, err := netip.ParsePrefix(tn.Prefix)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tn.Name, err)
+		}
+		if !p.Addr().Is4() || p != p.Masked() {
+			return fmt.Errorf("tenant %s: prefix %s must be a masked IPv4 prefix", tn.Name, tn.Prefix)
+		}
+		if b, ok := addrs.Overlapping(p); ok {
+			return fmt.Errorf("tenant %s: prefix %s overlaps the fabric address block %s", tn.Name, tn.Prefix, b)
+		}
+		for _, q := range prefixes {
+			if p.Overlaps(q) {
+				return fmt.Errorf("tenant %s: prefix %s overlaps %s", tn.Name, tn.Prefix, q)
+			}
+		}
+		prefixes = append(prefixes, p)
+
+		if len(tn.Servers) == 0 {
+			return fmt.Errorf("tenant %s: no servers", tn.Name)
+		}
+		for _, s := range tn.Servers {
+			if _, err := path.Match(s, ""); err != nil {
+				return fmt.Errorf("tenant %s: invalid pattern %q", tn.Name, s)
+			}
+		}
+	}
+	return nil
+}
+
+// matchesServer reports whether a server is a member of the tenant.
+func (tn Tenant) matchesServer(name string) bool {
+	for _, s := range tn.Servers {
+		if ok, _ := path.Match(s, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantData holds the VRF of one tenant on a node for template rendering.
+type TenantData struct {
+	Name      string
+	Table     int        // Kernel routing table
+	Prefix    string     // Tenant prefix
+	Address   string     // Tenant address of a server (empty on switches)
+	SpineASNs string     // Spine ASN range when a Leaf must not send Spine routes back to the Spines
+	Neighbors []Neighbor // Tenant BGP sessions on VLAN subinterfaces
+}
+
+// tenantPlan holds the nodes and server addresses of a tenant.
+type tenantPlan struct {
+	Tenant
+	members   map[string]bool   // Nodes carrying the VRF
+	addresses map[string]string // Server name -> tenant address
+}
+
+// planTenants assigns member nodes and server addresses to each tenant.
+// A ToR joins if any of its servers does, a Leaf pair if any of its ToRs
+// does, and the Spines if the tenant spans more than one Leaf pair.
+func (t *Topology) planTenants() error {
+	t.tenants = nil
+	for _, tn := range t.config.Definition.Tenants {
+		plan := tenantPlan{
+			Tenant:    tn,
+			members:   make(map[string]bool),
+			addresses: make(map[string]string),
+		}
+		prefix := netip.MustParsePrefix(tn.Prefix)
+		addr := prefix.Addr()
+		pods := 0
+
+		for pairIdx := 0; pairIdx < t.config.NumLeafPairs; pairIdx++ {
+			podMember := false
+			for torIdx := 0; torIdx < t.config.NumToRsPerLeafPair; torIdx++ {
+				globalToRIdx := pairIdx*t.config.NumToRsPerLeafPair + torIdx
+				rackMember := false
+				for srvIdx := 0; srvIdx < t.config.NumServersPerToR; srvIdx++ {
+					globalSrvIdx := globalToRIdx*t.config.NumServersPerToR + srvIdx
+					srvName := fmt.Sprintf("server%d-as%d", globalSrvIdx, t.asn.ServerASN(globalSrvIdx))
+					if !tn.matchesServer(srvName) {
+						continue
+					}
+					addr = addr.Next()
+					if !prefix.Contains(addr) {
+						return fmt.Errorf("tenant %s: prefix %s is too small for its servers", tn.Name, tn.Prefix)
+					}
+					plan.members[srvName] = true
+					plan.addresses[srvName] = addr.String()
+					rackMember = true
+				}
+				if rackMember {
+					plan.members[fmt.Sprintf("tor%d-as%d", globalToRIdx, t.torASN(pairIdx, torIdx))] = true
+					podMember = true
+				}
+			}
+			if podMember {
+				for leafNum := 1; leafNum <= 2; leafNum++ {
+					plan.members[fmt.Sprintf("leaf%d-as%d", leafNum, t.asn.LeafASN(pairIdx))] = true
+				}
+				pods++
+			}
+		}
+
+		if len(plan.addresses) == 0 {
+			return fmt.Errorf("tenant %s does not match any server", tn.Name)
+		}
+		if pods > 1 {
+			for i := 0; i < t.config.NumSpines; i++ {
+				plan.members[fmt.Sprintf("spine%d", i)] = true
+			}
+		}
+		t.tenants = append(t.tenants, plan)
+	}
+	return nil
+}
+
+// tenantData returns the tenant VRFs of a node. Each tenant session copies
+// the settings of the underlying session, but uses the tenant's
+// subinterface and filter. With unique Spine ASNs, Leafs export to the
+// Spines through a filter that also rejects routes learned from a Spine,
+// like leaf_export_to_spine.
+func (t *Topology) tenantData(name string, neighbors []Neighbor) []TenantData {
+	spineASNs := t.asn.SpineASNRange(t.config.NumSpines)
+	var out []TenantData
+	for _, plan := range t.tenants {
+		if !plan.members[name] {
+			continue
+		}
+		td := TenantData{
+			Name:    plan.Name,
+			Table:   TenantTableBase + plan.ID,
+			Prefix:  plan.Prefix,
+			Address: plan.addresses[name],
+		}
+		for _, n := range neighbors {
+			info := t.peerLLAs[name][n.Interface]
+			if !plan.members[info.PeerNode] {
+				continue
+			}
+			subIf := fmt.Sprintf("%s.%d", n.Interface, plan.ID)
+			lla, _, _ := strings.Cut(n.PeerLLA, "%")
+
+			tnb := n
+			tnb.Name = plan.Name + "_" + n.Name
+			tnb.VRF = plan.Name
+			tnb.Interface = subIf
+			tnb.PeerLLA = lla + "%" + subIf
+			tnb.Numbered = false
+			tnb.ImportFilter = "tenant_" + plan.Name
+			tnb.ExportFilter = "tenant_" + plan.Name
+			if spineASNs != "" && NodeRole(name) == "leaf" && NodeRole(info.PeerNode) == "spine" {
+				td.SpineASNs = spineASNs
+				tnb.ExportFilter += "_to_spine"
+			}
+			tnb.BaseImportFilter = tnb.ImportFilter
+			tnb.BaseExportFilter = tnb.ExportFilter
+			tnb.ImportTE = TEAction{}
+			tnb.ExportTE = TEAction{}
+			tnb.LocalPref = 0
+			tnb.MaxPrefix = t.config.ReceiveLimit(len(plan.addresses))
+			td.Neighbors = append(td.Neighbors, tnb)
+		}
+		out = append(out, td)
+	}
+	return out
+}
+
+// tenantCmds returns the commands that create the tenant VRFs of a node:
+// the VRF device, a VLAN subinterface per tenant session and, on servers,
+// a loopback holding the tenant address. Subinterfaces inherit the MAC of
+// their parent, so the kernel assigns them the same LLA.
+func tenantCmds(tenants []TenantData) []Command {
+	var cmds []Command
+	for _, td := range tenants {
+		cmds = append(cmds,
+			Command{Cmd: fmt.Sprintf("ip link add %s type vrf table %d", td.Name, td.Table)},
+			Command{Cmd: fmt.Sprintf("ip link set dev %s up", td.Name)},
+		)
+		for _, n := range td.Neighbors {
+			parent, id, _ := strings.Cut(n.Interface, ".")
+			cmds = append(cmds,
+				Command{Cmd: fmt.Sprintf("ip link add link %s name %s type vlan id %s", parent, n.Interface, id)},
+				Command{Cmd: fmt.Sprintf("ip link set dev %s master %s", n.Interface, td.Name)},
+				Command{Cmd: fmt.Sprintf("ip link set dev %s up", n.Interface)},
+			)
+		}
+		if td.Address != "" {
+			lo := td.Name + "-lo"
+			cmds = append(cmds,
+				Command{Cmd: fmt.Sprintf("ip link add %s type dummy", lo)},
+				Command{Cmd: fmt.Sprintf("ip link set dev %s master %s", lo, td.Name)},
+				Command{Cmd: fmt.Sprintf("ip link set dev %s up", lo)},
+				Command{Cmd: fmt.Sprintf("ip addr add %s/32 dev %s", td.Address, lo)},
+			)
+		}
+	}
+	return cmds
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTenants(t *testing.T) {
+	red := Tenant{Name: "red", ID: 10, Prefix: "172.16.0.0/24", Servers: []string{"server0-*", "server5-*"}}
+	tenants := func(c *Config) {
+		c.NumLeafPairs = 2
+		c.Definition.Tenants = Tenants{red, {Name: "blue", ID: 20, Prefix: "172.17.0.0/24", Servers: []string{"server1-*"}}}
+	}
+	topo, spec := buildTestTopology(t, tenants)
+	configs := topo.GetBirdConfigs()
+
+	// red spans both pods, so it reaches the Spines; blue stays in pod 0
+	for name, want := range map[string]int{
+		"server0-as4200100000": 1,
+		"server1-as4200100001": 0,
+		"tor0-as4200010000":    3,
+		"tor1-as4200010001":    0,
+		"leaf1-as4200001000":   3,
+		"spine0":               4,
+		"bl0":                  0,
+	} {
+		if got := strings.Count(configs[name], "protocol bgp red_"); got != want {
+			t.Errorf("%s has %d red sessions, want %d", name, got, want)
+		}
+	}
+	if strings.Contains(configs["spine0"], "blue") {
+		t.Errorf("spine0 should not carry blue")
+	}
+
+	server := configs["server0-as4200100000"]
+	for _, want := range []string{
+		"ipv4 table t_red;",
+		"if net ~ [ 172.16.0.0/24{32,32} ] then accept;",
+		"kernel table 1010;",
+		"neighbor fe80::80:ff:fe00:0%tr0.10 as 4200010000;",
+		"import filter tenant_red;",
+	} {
+		if !strings.Contains(server, want) {
+			t.Errorf("server0 config missing %q", want)
+		}
+	}
+
+	cmds := nodeCmds(spec)
+	for node, want := range map[string][]string{
+		"server0-as4200100000": {
+			"ip link add red type vrf table 1010",
+			"ip link add link tr0 name tr0.10 type vlan id 10",
+			"ip addr add 172.16.0.1/32 dev red-lo",
+		},
+		"server5-as4200100005": {"ip addr add 172.16.0.2/32 dev red-lo"},
+		"spine0":               {"ip link set dev lf3.10 master red"},
+	} {
+		for _, w := range want {
+			if !slices.Contains(cmds[node], w) {
+				t.Errorf("%s missing command %q", node, w)
+			}
+		}
+	}
+
+	with := func(tn Tenant) func(*Config) {
+		return func(c *Config) {
+			tenants(c)
+			c.Definition.Tenants = Tenants{red, tn}
+		}
+	}
+	expectInvalid(t, map[string]func(*Config){
+		"uppercase name":   with(Tenant{Name: "Red", ID: 10, Prefix: "172.16.0.0/24", Servers: []string{"*"}}),
+		"duplicate ID":     with(Tenant{Name: "green", ID: 10, Prefix: "172.18.0.0/24", Servers: []string{"*"}}),
+		"overlapping":      with(Tenant{Name: "green", ID: 30, Prefix: "172.16.0.0/16", Servers: []string{"*"}}),
+		"fabric prefix":    with(Tenant{Name: "green", ID: 30, Prefix: "10.0.1.0/24", Servers: []string{"*"}}),
+		"VLAN ID too high": with(Tenant{Name: "green", ID: 5000, Prefix: "172.18.0.0/24", Servers: []string{"*"}}),
+		"prefix too small for its servers": func(c *Config) {
+			c.Definition.Tenants = Tenants{{Name: "green", ID: 30, Prefix: "172.18.0.0/30", Servers: []string{"*"}}}
+		},
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tierOrder ranks roles so that tier keys are written top-down
+// (e.g. "spine-leaf", "leaf-tor", "bl-router").
+var tierOrder = map[string]int{
+	"spine":  0,
+	"leaf":   1,
+	"tor":    2,
+	"server": 3,
+	"bl":     4,
+	"router": 5,
+}
+
+// TierKey returns the key identifying sessions between two roles,
+// with the upper tier first (e.g. "spine-leaf").
+func TierKey(role1, role2 string) string {
+	if tierOrder[role2] < tierOrder[role1] {
+		role1, role2 = role2, role1
+	}
+	return role1 + "-" + role2
+}
+
+// SessionKey returns a key identifying a session that is the same from
+// both ends.
+func SessionKey(node1, if1, node2, if2 string) string {
+	ends := []string{node1 + "#" + if1, node2 + "#" + if2}
+	sort.Strings(ends)
+	return strings.Join(ends, " ")
+}
+
+// NodeRole returns the role of a node from its name prefix.
+func NodeRole(name string) string {
+	for role := range tierOrder {
+		if strings.HasPrefix(name, role) {
+			return role
+		}
+	}
+	return ""
+}
+
+// isRole reports whether name is a known role.
+func isRole(name string) bool {
+	_, ok := tierOrder[name]
+	return ok
+}
+
+// ValidTierKey reports whether key is a tier key as returned by TierKey.
+func ValidTierKey(key string) bool {
+	roles := strings.SplitN(key, "-", 2)
+	return len(roles) == 2 && isRole(roles[0]) && isRole(roles[1]) && TierKey(roles[0], roles[1]) == key
+}
+
+// checkLinks returns an error if a link assignment does not match any BGP
+// session, or if the two ends of a session are assigned different values.
+func checkLinks[V comparable](what string, links map[string]V, ends map[string]string) error {
+	keys := make([]string, 0, len(links))
+	for key := range links {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var unused []string
+	for _, key := range keys {
+		peer, ok := ends[key]
+		if !ok {
+			unused = append(unused, key)
+			continue
+		}
+		if v, ok := links[peer]; ok && v != links[key] && key < peer {
+			return fmt.Errorf("%s links %s and %s are ends of the same session but set to %v and %v",
+				what, key, peer, links[key], v)
+		}
+	}
+	if len(unused) > 0 {
+		return fmt.Errorf("%s links do not match any BGP session: %s", what, strings.Join(unused, ", "))
+	}
+	return nil
+}
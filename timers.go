@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TimerProfileDefault is the profile used when no tier or link assignment matches.
+const TimerProfileDefault = "default"
+
+// TimerProfile holds BFD and BGP timer values for a session.
+type TimerProfile struct {
+	BFDInterval     int `yaml:"bfd_interval"`      // BFD min rx/tx interval (ms)
+	BFDIdleInterval int `yaml:"bfd_idle_interval"` // BFD tx interval while the session is down (ms)
+	BFDMultiplier   int `yaml:"bfd_multiplier"`    // Missed BFD packets before the session is declared down
+	HoldTime        int `yaml:"hold_time"`         // BGP hold time (s)
+	KeepaliveTime   int `yaml:"keepalive_time"`    // BGP keepalive time (s)
+}
+
+// builtinTimerProfiles are the profiles available without a topology definition.
+// The default profile matches BIRD's BGP defaults.
+var builtinTimerProfiles = map[string]TimerProfile{
+	"aggressive": {BFDInterval: 50, BFDIdleInterval: 1000, BFDMultiplier: 3, HoldTime: 9, KeepaliveTime: 3},
+	"default":    {BFDInterval: 100, BFDIdleInterval: 1000, BFDMultiplier: 3, HoldTime: 240, KeepaliveTime: 80},
+	"relaxed":    {BFDInterval: 300, BFDIdleInterval: 1000, BFDMultiplier: 5, HoldTime: 240, KeepaliveTime: 80},
+}
+
+// TimerDefinition assigns timer profiles in the topology definition.
+type TimerDefinition struct {
+	Profiles map[string]TimerProfile `yaml:"profiles"` // Custom profiles (may replace built-in ones)
+	Tiers    map[string]string       `yaml:"tiers"`    // Profile per tier key (e.g. spine-leaf)
+	Links    map[string]string       `yaml:"links"`    // Profile per link, keyed by either end "node#iface"
+}
+
+// TimerPlan assigns timer profiles to BGP sessions.
+type TimerPlan struct {
+	Default  string
+	Profiles map[string]TimerProfile
+	Tiers    map[string]string
+	Links    map[string]string
+}
+
+// NewTimerPlan returns the timer plan for a configuration.
+func NewTimerPlan(c Config) TimerPlan {
+	profiles := make(map[string]TimerProfile)
+	for name, p := range builtinTimerProfiles {
+		profiles[name] = p
+	}
+	for name, p := range c.Definition.Timers.Profiles {
+		profiles[name] = p
+	}
+	return TimerPlan{
+		Default:  c.TimerProfile,
+		Profiles: profiles,
+		Tiers:    c.Definition.Timers.Tiers,
+		Links:    c.Definition.Timers.Links,
+	}
+}
+
+// Validate checks that all profiles are usable and all assignments refer
+// to known profiles.
+func (p TimerPlan) Validate() error {
+	for name, prof := range p.Profiles {
+		if err := prof.validate(); err != nil {
+			return fmt.Errorf("timer profile %q: %w", name, err)
+		}
+	}
+	if _, ok := p.Profiles[p.Default]; !ok {
+		return fmt.Errorf("unknown timer profile %q (must be one of %s)", p.Default, p.names())
+	}
+	for key, name := range p.Tiers {
+		if !ValidTierKey(key) {
+			return fmt.Errorf("invalid tier key %q in timers (e.g. spine-leaf)", key)
+		}
+		if _, ok := p.Profiles[name]; !ok {
+			return fmt.Errorf("unknown timer profile %q for tier %s", name, key)
+		}
+	}
+	for key, name := range p.Links {
+		if !strings.Contains(key, "#") {
+			return fmt.Errorf("invalid link key %q in timers (e.g. spine0#lf0)", key)
+		}
+		if _, ok := p.Profiles[name]; !ok {
+			return fmt.Errorf("unknown timer profile %q for link %s", name, key)
+		}
+	}
+	return nil
+}
+
+// Profile returns the profile name for a session. A link assignment on
+// either end takes precedence over a tier assignment, which takes
+// precedence over the default.
+func (p TimerPlan) Profile(local, localIf, peer, peerIf string) string {
+	for _, end := range []string{local + "#" + localIf, peer + "#" + peerIf} {
+		if name, ok := p.Links[end]; ok {
+			return name
+		}
+	}
+	if name, ok := p.Tiers[TierKey(NodeRole(local), NodeRole(peer))]; ok {
+		return name
+	}
+	return p.Default
+}
+
+// names returns the sorted profile names.
+func (p TimerPlan) names() string {
+	var names []string
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// validate checks the timer values against BIRD's limits.
+func (t TimerProfile) validate() error {
+	if t.BFDInterval <= 0 || t.BFDIdleInterval <= 0 {
+		return fmt.Errorf("BFD intervals must be positive")
+	}
+	if t.BFDMultiplier < 1 || t.BFDMultiplier > 255 {
+		return fmt.Errorf("BFD multiplier must be 1-255")
+	}
+	if t.HoldTime < 3 {
+		return fmt.Errorf("hold time must be at least 3 seconds")
+	}
+	if t.KeepaliveTime < 1 || t.KeepaliveTime >= t.HoldTime {
+		return fmt.Errorf("keepalive time must be positive and less than hold time")
+	}
+	return nil
+}
@@ -8,12 +8,33 @@ import (
 type Topology struct {
 	config      Config
 	templates   *Templates
+	asn         ASNPlan
+	addrs       AddressPlan
+	auth        BGPAuth
+	ttl         TTLSecurityPlan
+	timers      TimerPlan
+	rpki        RPKI
+	tenants     []tenantPlan
+	teMatched   map[int]bool // TE policies matched by a session
+	nodes       []NodeInfo
 	nodeConfigs []NodeConfig
 	interfaces  map[string][]Interface
 	birdConfigs map[string]string
-	linkID      uint32                         // Link ID counter for MAC generation
 	macCmds     map[string][]string            // MAC setting commands per node
 	peerLLAs    map[string]map[string]peerInfo // node -> interface -> peer info
+	sessionEnds map[string]string              // Local end "node#iface" of each BGP session -> peer end
+}
+
+// NodeInfo holds the identifiers allocated to a node.
+type NodeInfo struct {
+	Name     string
+	Role     string
+	ASN      int
+	RouterID string
+
+	Loopbacks []string // Addresses on lo, with prefix length
+	Workload  string   // Address on the workload bridge, with prefix length (servers)
+	Gateway   string   // Address on the rack bridge, with prefix length (ToRs in L2 racks)
 }
 
 // peerInfo holds peer information for a link.
@@ -21,6 +42,11 @@ type peerInfo struct {
 	PeerLLA  string
 	PeerASN  int
 	LocalLLA string
+	LocalMAC string
+	PeerNode string
+	PeerIf   string
+	Numbered *linkAddrs // Addresses of a numbered link (nil if unnumbered)
+	Address  string     // Address on an L2 rack segment, with prefix length (servers)
 }
 
 // NewTopology creates a new topology builder.
@@ -32,11 +58,31 @@ func NewTopology(cfg Config, templates *Templates) *Topology {
 		birdConfigs: make(map[string]string),
 		macCmds:     make(map[string][]string),
 		peerLLAs:    make(map[string]map[string]peerInfo),
+		sessionEnds: make(map[string]string),
 	}
 }
 
 // Build generates the complete tinet specification.
 func (t *Topology) Build() (Spec, error) {
+	plan, err := NewASNPlan(t.config.ASNScheme, t.config.Definition.ASN)
+	if err != nil {
+		return Spec{}, err
+	}
+	t.asn = plan
+	if t.addrs, err = NewAddressPlan(t.config.Definition.Addresses); err != nil {
+		return Spec{}, err
+	}
+	t.auth = NewBGPAuth(t.config)
+	t.ttl = NewTTLSecurityPlan(t.config)
+	t.timers = NewTimerPlan(t.config)
+	if t.rpki, err = NewRPKI(t.config); err != nil {
+		return Spec{}, err
+	}
+	if err := t.planTenants(); err != nil {
+		return Spec{}, err
+	}
+	t.teMatched = make(map[int]bool)
+
 	if err := t.buildSpines(); err != nil {
 		return Spec{}, err
 	}
@@ -55,6 +101,24 @@ func (t *Topology) Build() (Spec, error) {
 	if err := t.buildRouters(); err != nil {
 		return Spec{}, err
 	}
+	if err := checkLinks("TTL security", t.ttl.Links, t.sessionEnds); err != nil {
+		return Spec{}, err
+	}
+	if err := checkLinks("timer", t.timers.Links, t.sessionEnds); err != nil {
+		return Spec{}, err
+	}
+	if err := t.checkTEPolicies(); err != nil {
+		return Spec{}, err
+	}
+	if err := t.checkMRTNodes(); err != nil {
+		return Spec{}, err
+	}
+	if err := t.checkMACs(); err != nil {
+		return Spec{}, err
+	}
+	if t.config.RoutingMode == RoutingModeOSPF {
+		t.addUnnumberedAddrs()
+	}
 
 	spec := Spec{
 		Nodes:       t.buildNodes(),
@@ -79,6 +143,23 @@ func (t *Topology) GetBirdConfigs() map[string]string {
 	return t.birdConfigs
 }
 
+// SessionCount returns the number of BGP sessions of a node: its links to
+// nodes running BIRD.
+func (t *Topology) SessionCount(name string) int {
+	n := 0
+	for _, info := range t.peerLLAs[name] {
+		if _, ok := t.birdConfigs[info.PeerNode]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// GetNodes returns the identifiers allocated to each node in build order.
+func (t *Topology) GetNodes() []NodeInfo {
+	return t.nodes
+}
+
 // addInterface adds an interface definition to a node.
 func (t *Topology) addInterface(nodeName, ifName, targetNode, targetIf string) {
 	t.interfaces[nodeName] = append(t.interfaces[nodeName], Interface{
@@ -98,15 +179,15 @@ func (t *Topology) addBridgeInterface(nodeName, ifName, bridgeName string) {
 }
 
 // addLink creates a link between two nodes and sets up MAC/LLA mappings.
-// Returns the peer LLAs for each side (what node1 sees, what node2 sees).
+// node1 is the upper-tier end; linkID is derived from the endpoints (see LinkID).
 func (t *Topology) addLink(
+	linkID uint32,
 	node1, if1 string, asn1 int,
 	node2, if2 string, asn2 int,
 ) {
 	// Generate MAC addresses for both ends
-	mac1 := GenerateMAC(t.linkID)
-	mac2 := GenerateMAC(t.linkID + 1)
-	t.linkID += 2
+	mac1 := GenerateMAC(t.config.MACFabricID(), linkID)
+	mac2 := GenerateMAC(t.config.MACFabricID(), linkID+1)
 
 	// Calculate LLAs
 	lla1 := MACToLLA(mac1)
@@ -122,6 +203,15 @@ func (t *Topology) addLink(
 		fmt.Sprintf("ip -6 addr add %s/64 dev %s", lla2, if2),
 	)
 
+	// Numbered links also get a /31 (and /127) on both ends
+	var num1, num2 *linkAddrs
+	if t.config.Numbered(TierKey(NodeRole(node1), NodeRole(node2))) {
+		end1, end2 := t.numberLink(linkID)
+		num1, num2 = &end1, &end2
+		t.macCmds[node1] = append(t.macCmds[node1], end1.cmds(if1)...)
+		t.macCmds[node2] = append(t.macCmds[node2], end2.cmds(if2)...)
+	}
+
 	// Add interface (one side only, tinet auto-generates reverse)
 	t.addInterface(node1, if1, node2, if2)
 
@@ -138,11 +228,19 @@ func (t *Topology) addLink(
 		PeerLLA:  FormatLLAWithInterface(lla2, if1),
 		PeerASN:  asn2,
 		LocalLLA: lla1.String(),
+		LocalMAC: mac1.String(),
+		PeerNode: node2,
+		PeerIf:   if2,
+		Numbered: num1,
 	}
 	t.peerLLAs[node2][if2] = peerInfo{
 		PeerLLA:  FormatLLAWithInterface(lla1, if2),
 		PeerASN:  asn1,
 		LocalLLA: lla2.String(),
+		LocalMAC: mac2.String(),
+		PeerNode: node1,
+		PeerIf:   if1,
+		Numbered: num2,
 	}
 }
 
@@ -156,6 +254,113 @@ func (t *Topology) getPeerInfo(nodeName, ifName string) (peerLLA string, peerASN
 	return "", 0, ""
 }
 
+// applySessionSettings sets the numbered addresses, password, TTL security,
+// receive limit action, egress preference and timers of each neighbor.
+func (t *Topology) applySessionSettings(name string, neighbors []Neighbor) {
+	for i := range neighbors {
+		n := &neighbors[i]
+		info := t.peerLLAs[name][n.Interface]
+		if info.Numbered != nil {
+			n.Numbered = true
+			n.PeerAddress = info.Numbered.Peer
+			n.LocalAddress = info.Numbered.Local
+		}
+		n.Password = t.auth.Password(name, n.Interface, info.PeerNode, info.PeerIf)
+		n.TTLSecurity = t.ttl.Enabled(name, n.Interface, info.PeerNode, info.PeerIf)
+		t.sessionEnds[name+"#"+n.Interface] = info.PeerNode + "#" + info.PeerIf
+		n.MaxPrefixAction = t.config.ReceiveLimitAction
+		n.LocalPref = t.config.Definition.Egress.ImportLocalPref(name, info.PeerNode)
+
+		n.Profile = t.timers.Profile(name, n.Interface, info.PeerNode, info.PeerIf)
+		n.TimerProfile = t.timers.Profiles[n.Profile]
+
+		t.applyTE(name, info.PeerNode, n)
+	}
+}
+
+// applyTE replaces the role filters of a neighbor with per-neighbor filters
+// when traffic engineering policies match the session or the node is drained.
+func (t *Topology) applyTE(name, peer string, n *Neighbor) {
+	imp, exp, matched := t.config.Definition.Policies.Actions(name, peer)
+	for _, i := range matched {
+		t.teMatched[i] = true
+	}
+	if name == t.config.DrainNode {
+		drainImp, drainExp := t.config.drainActions()
+		imp = imp.merge(drainImp)
+		exp = exp.merge(drainExp)
+	}
+
+	n.BaseImportFilter = n.ImportFilter
+	n.BaseExportFilter = n.ExportFilter
+	n.ImportTE = imp
+	n.ExportTE = exp
+	if imp.Active() {
+		n.ImportFilter = "te_import_" + n.Name
+	}
+	if exp.Active() {
+		n.ExportFilter = "te_export_" + n.Name
+	}
+}
+
+// checkTEPolicies returns an error if a TE policy does not match any BGP session.
+func (t *Topology) checkTEPolicies() error {
+	for i, p := range t.config.Definition.Policies {
+		if !t.teMatched[i] {
+			return fmt.Errorf("policy %d (%s -> %s) does not match any BGP session", i, p.Node, p.Peer)
+		}
+	}
+	return nil
+}
+
+// torASN returns the ASN for a ToR under the configured ASN scheme.
+func (t *Topology) torASN(pairIdx, torIdx int) int {
+	return t.asn.ToRASN(pairIdx, torIdx, t.config.NumToRsPerLeafPair)
+}
+
+// torAllowLocalAS returns the allow local as count for ToR uplinks.
+// ToRs sharing an ASN must accept each other's routes.
+func (t *Topology) torAllowLocalAS() int {
+	if t.asn.ReuseToR {
+		return AllowLocalASReuse
+	}
+	return 0
+}
+
+// community returns the large community values for a node.
+func (t *Topology) community(roleCode, pod, rack int) Community {
+	return NodeCommunity(t.asn.SpineASN(0), roleCode, pod, rack)
+}
+
+// serverRouterID returns the router ID for a server.
+// With aggregation, servers are allocated from per-rack blocks so that
+// each rack and pod can be summarized by a single prefix. In L2 racks the
+// block is the rack subnet and its first host is the gateway.
+func (t *Topology) serverRouterID(pairIdx, torIdx, srvIdx, globalSrvIdx int) string {
+	if t.config.L2Racks() {
+		return t.addrs.RackServerRouterID(t.rackSlot(pairIdx, torIdx), srvIdx+1, t.rackBlockSize())
+	}
+	if t.config.Aggregate {
+		return t.addrs.RackServerRouterID(t.rackSlot(pairIdx, torIdx), srvIdx, t.rackBlockSize())
+	}
+	return t.addrs.ServerRouterID(globalSrvIdx)
+}
+
+// rackSlot returns the server address block index for a ToR.
+func (t *Topology) rackSlot(pairIdx, torIdx int) int {
+	return pairIdx*t.rackSlots() + torIdx
+}
+
+// rackSlots returns the number of server address blocks reserved per leaf pair.
+func (t *Topology) rackSlots() int {
+	return PodRackSlots(t.config.NumToRsPerLeafPair)
+}
+
+// rackBlockSize returns the number of server addresses reserved per rack.
+func (t *Topology) rackBlockSize() int {
+	return t.config.rackBlockSize()
+}
+
 func (t *Topology) buildNodes() []Node {
 	var nodes []Node
 	for _, nc := range t.nodeConfigs {
@@ -171,17 +376,19 @@ func (t *Topology) buildNodes() []Node {
 func (t *Topology) buildSpines() error {
 	for i := 0; i < t.config.NumSpines; i++ {
 		name := fmt.Sprintf("spine%d", i)
-		routerID := SpineRouterID(i)
+		routerID := t.addrs.SpineRouterID(i)
+		spineASN := t.asn.SpineASN(i)
 
 		// Connect to Leafs
 		for pairIdx := 0; pairIdx < t.config.NumLeafPairs; pairIdx++ {
-			leafASN := LeafASN(pairIdx)
+			leafASN := t.asn.LeafASN(pairIdx)
 			for leafNum := 1; leafNum <= 2; leafNum++ {
 				leafName := fmt.Sprintf("leaf%d-as%d", leafNum, leafASN)
 				myIf := fmt.Sprintf("lf%d", pairIdx*2+(leafNum-1))
 				peerIf := fmt.Sprintf("sp%d", i)
 
-				t.addLink(name, myIf, ASNSpine, leafName, peerIf, leafASN)
+				t.addLink(LinkID(linkTierSpineLeaf, i, pairIdx*2+leafNum-1),
+					name, myIf, spineASN, leafName, peerIf, leafASN)
 			}
 		}
 
@@ -190,7 +397,8 @@ func (t *Topology) buildSpines() error {
 			myIf := fmt.Sprintf("bl%d", blIdx)
 			peerIf := fmt.Sprintf("sp%d", i)
 
-			t.addLink(name, myIf, ASNSpine, fmt.Sprintf("bl%d", blIdx), peerIf, ASNBorderLeaf)
+			t.addLink(LinkID(linkTierSpineBL, i, blIdx),
+				name, myIf, spineASN, fmt.Sprintf("bl%d", blIdx), peerIf, t.asn.BorderLeafASN())
 		}
 
 		// Build neighbors
@@ -198,7 +406,7 @@ func (t *Topology) buildSpines() error {
 
 		// Leaf neighbors
 		for pairIdx := 0; pairIdx < t.config.NumLeafPairs; pairIdx++ {
-			leafASN := LeafASN(pairIdx)
+			leafASN := t.asn.LeafASN(pairIdx)
 			for leafNum := 1; leafNum <= 2; leafNum++ {
 				myIf := fmt.Sprintf("lf%d", pairIdx*2+(leafNum-1))
 				peerLLA, peerASN, localLLA := t.getPeerInfo(name, myIf)
@@ -211,7 +419,7 @@ func (t *Topology) buildSpines() error {
 					LocalLLA:     localLLA,
 					ImportFilter: "spine_import",
 					ExportFilter: "spine_export",
-					MaxPrefix:    500,
+					MaxPrefix:    t.config.ReceiveLimit(t.config.PodPrefixes()),
 				})
 			}
 		}
@@ -229,11 +437,17 @@ func (t *Topology) buildSpines() error {
 				LocalLLA:     localLLA,
 				ImportFilter: "spine_import",
 				ExportFilter: "spine_export",
-				MaxPrefix:    100,
+				MaxPrefix:    t.config.ReceiveLimit(t.config.EdgePrefixes()),
 			})
 		}
 
-		if err := t.addNodeConfig(name, routerID, "spine", ASNSpine, neighbors, false); err != nil {
+		data := TemplateData{
+			RouterID:  routerID,
+			ASN:       spineASN,
+			Neighbors: neighbors,
+			Community: t.community(RoleCodeSpine, -1, -1),
+		}
+		if err := t.addNodeConfig(name, "spine", data, false); err != nil {
 			return err
 		}
 	}
@@ -242,21 +456,22 @@ func (t *Topology) buildSpines() error {
 
 func (t *Topology) buildLeafs() error {
 	for pairIdx := 0; pairIdx < t.config.NumLeafPairs; pairIdx++ {
-		leafASN := LeafASN(pairIdx)
+		leafASN := t.asn.LeafASN(pairIdx)
 
 		for leafNum := 1; leafNum <= 2; leafNum++ {
 			name := fmt.Sprintf("leaf%d-as%d", leafNum, leafASN)
-			routerID := LeafRouterID(pairIdx, leafNum)
+			routerID := t.addrs.LeafRouterID(pairIdx, leafNum)
 
 			// Connect to ToRs
 			for torIdx := 0; torIdx < t.config.NumToRsPerLeafPair; torIdx++ {
 				globalToRIdx := pairIdx*t.config.NumToRsPerLeafPair + torIdx
-				torASN := ToRASN(globalToRIdx)
+				torASN := t.torASN(pairIdx, torIdx)
 				torName := fmt.Sprintf("tor%d-as%d", globalToRIdx, torASN)
 				myIf := fmt.Sprintf("tr%d", torIdx)
 				peerIf := fmt.Sprintf("lf%d", leafNum-1)
 
-				t.addLink(name, myIf, leafASN, torName, peerIf, torASN)
+				t.addLink(LinkID(linkTierLeafToR, pairIdx*2+leafNum-1, torIdx),
+					name, myIf, leafASN, torName, peerIf, torASN)
 			}
 
 			// Build neighbors
@@ -275,7 +490,7 @@ func (t *Topology) buildLeafs() error {
 					LocalLLA:     localLLA,
 					ImportFilter: "leaf_import_from_spine",
 					ExportFilter: "leaf_export_to_spine",
-					MaxPrefix:    1000,
+					MaxPrefix:    t.config.ReceiveLimit(t.config.FabricPrefixes()),
 				})
 			}
 
@@ -293,11 +508,21 @@ func (t *Topology) buildLeafs() error {
 					LocalLLA:     localLLA,
 					ImportFilter: "leaf_import_from_tor",
 					ExportFilter: "leaf_export_to_tor",
-					MaxPrefix:    100,
+					MaxPrefix:    t.config.ReceiveLimit(t.config.RackPrefixes()),
 				})
 			}
 
-			if err := t.addNodeConfig(name, routerID, "leaf", leafASN, neighbors, false); err != nil {
+			data := TemplateData{
+				RouterID:  routerID,
+				ASN:       leafASN,
+				Neighbors: neighbors,
+				Community: t.community(RoleCodeLeaf, pairIdx, -1),
+			}
+			if t.config.Aggregate {
+				data.Aggregate = t.addrs.PodAggregate(pairIdx, t.rackSlots(), t.rackBlockSize())
+				data.AggregateSummaryOnly = t.config.AggregateSummaryOnly
+			}
+			if err := t.addNodeConfig(name, "leaf", data, false); err != nil {
 				return err
 			}
 		}
@@ -308,7 +533,7 @@ func (t *Topology) buildLeafs() error {
 func (t *Topology) buildBorderLeafs() error {
 	for blIdx := 0; blIdx < t.config.NumBorderLeafs; blIdx++ {
 		name := fmt.Sprintf("bl%d", blIdx)
-		routerID := BorderLeafRouterID(blIdx)
+		routerID := t.addrs.BorderLeafRouterID(blIdx)
 
 		// Connect to Routers
 		for rtIdx := 0; rtIdx < t.config.NumRouters; rtIdx++ {
@@ -316,7 +541,8 @@ func (t *Topology) buildBorderLeafs() error {
 			myIf := fmt.Sprintf("rt%d", rtIdx)
 			peerIf := fmt.Sprintf("bl%d", blIdx)
 
-			t.addLink(name, myIf, ASNBorderLeaf, rtName, peerIf, ASNRouter)
+			t.addLink(LinkID(linkTierBLRouter, blIdx, rtIdx),
+				name, myIf, t.asn.BorderLeafASN(), rtName, peerIf, t.asn.RouterASN())
 		}
 
 		// Build neighbors
@@ -335,28 +561,37 @@ func (t *Topology) buildBorderLeafs() error {
 				LocalLLA:     localLLA,
 				ImportFilter: "bl_import_from_spine",
 				ExportFilter: "bl_export_to_spine",
-				MaxPrefix:    1000,
+				MaxPrefix:    t.config.ReceiveLimit(t.config.FabricPrefixes()),
 			})
 		}
 
 		// Router neighbors
 		for rtIdx := 0; rtIdx < t.config.NumRouters; rtIdx++ {
+			router := fmt.Sprintf("router%d", rtIdx)
 			myIf := fmt.Sprintf("rt%d", rtIdx)
 			peerLLA, peerASN, localLLA := t.getPeerInfo(name, myIf)
 
 			neighbors = append(neighbors, Neighbor{
-				Name:         fmt.Sprintf("router%d", rtIdx),
+				Name:         router,
 				Interface:    myIf,
 				PeerASN:      peerASN,
 				PeerLLA:      peerLLA,
 				LocalLLA:     localLLA,
 				ImportFilter: "bl_import_from_router",
 				ExportFilter: "bl_export_to_router",
-				MaxPrefix:    10,
+				MaxPrefix:    t.config.ReceiveLimit(t.config.RouterPrefixes(router)),
 			})
 		}
 
-		if err := t.addNodeConfig(name, routerID, "bl", ASNBorderLeaf, neighbors, false); err != nil {
+		data := TemplateData{
+			RouterID:         routerID,
+			ASN:              t.asn.BorderLeafASN(),
+			Neighbors:        neighbors,
+			Community:        t.community(RoleCodeBL, -1, -1),
+			RPKI:             t.rpki,
+			ExternalPrefixes: t.config.Definition.ExternalPrefixes.PrefixSet(),
+		}
+		if err := t.addNodeConfig(name, "bl", data, false); err != nil {
 			return err
 		}
 	}
@@ -367,19 +602,20 @@ func (t *Topology) buildToRs() error {
 	for pairIdx := 0; pairIdx < t.config.NumLeafPairs; pairIdx++ {
 		for torIdx := 0; torIdx < t.config.NumToRsPerLeafPair; torIdx++ {
 			globalToRIdx := pairIdx*t.config.NumToRsPerLeafPair + torIdx
-			torASN := ToRASN(globalToRIdx)
+			torASN := t.torASN(pairIdx, torIdx)
 			name := fmt.Sprintf("tor%d-as%d", globalToRIdx, torASN)
-			routerID := ToRRouterID(globalToRIdx)
+			routerID := t.addrs.ToRRouterID(globalToRIdx)
 
 			// Connect to Servers
 			for srvIdx := 0; srvIdx < t.config.NumServersPerToR; srvIdx++ {
 				globalSrvIdx := globalToRIdx*t.config.NumServersPerToR + srvIdx
-				srvASN := ServerASN(globalSrvIdx)
+				srvASN := t.asn.ServerASN(globalSrvIdx)
 				srvName := fmt.Sprintf("server%d-as%d", globalSrvIdx, srvASN)
 				myIf := fmt.Sprintf("sv%d", srvIdx)
 				peerIf := "tr0"
 
-				t.addLink(name, myIf, torASN, srvName, peerIf, srvASN)
+				t.addLink(LinkID(linkTierToRServer, pairIdx, torIdx, srvIdx),
+					name, myIf, torASN, srvName, peerIf, srvASN)
 			}
 
 			// Build neighbors
@@ -398,29 +634,48 @@ func (t *Topology) buildToRs() error {
 					LocalLLA:     localLLA,
 					ImportFilter: "tor_import_from_leaf",
 					ExportFilter: "tor_export_to_leaf",
-					MaxPrefix:    500,
+					MaxPrefix:    t.config.ReceiveLimit(t.config.FabricPrefixes()),
+					AllowLocalAS: t.torAllowLocalAS(),
 				})
 			}
 
-			// Server neighbors
-			for srvIdx := 0; srvIdx < t.config.NumServersPerToR; srvIdx++ {
-				globalSrvIdx := globalToRIdx*t.config.NumServersPerToR + srvIdx
-				myIf := fmt.Sprintf("sv%d", srvIdx)
-				peerLLA, peerASN, localLLA := t.getPeerInfo(name, myIf)
-
-				neighbors = append(neighbors, Neighbor{
-					Name:         fmt.Sprintf("server%d", globalSrvIdx),
-					Interface:    myIf,
-					PeerASN:      peerASN,
-					PeerLLA:      peerLLA,
-					LocalLLA:     localLLA,
-					ImportFilter: "tor_import_from_server",
-					ExportFilter: "tor_export_to_server",
-					MaxPrefix:    10,
-				})
+			// Server neighbors (servers without BGP get static routes instead)
+			if !t.config.StaticServers() {
+				for srvIdx := 0; srvIdx < t.config.NumServersPerToR; srvIdx++ {
+					globalSrvIdx := globalToRIdx*t.config.NumServersPerToR + srvIdx
+					myIf := fmt.Sprintf("sv%d", srvIdx)
+					peerLLA, peerASN, localLLA := t.getPeerInfo(name, myIf)
+
+					neighbors = append(neighbors, Neighbor{
+						Name:         fmt.Sprintf("server%d", globalSrvIdx),
+						Interface:    myIf,
+						PeerASN:      peerASN,
+						PeerLLA:      peerLLA,
+						LocalLLA:     localLLA,
+						ImportFilter: "tor_import_from_server",
+						ExportFilter: "tor_export_to_server",
+						MaxPrefix:    t.config.ReceiveLimit(t.config.ServerPrefixes()),
+					})
+				}
 			}
 
-			if err := t.addNodeConfig(name, routerID, "tor", torASN, neighbors, false); err != nil {
+			data := TemplateData{
+				RouterID:  routerID,
+				ASN:       torASN,
+				Neighbors: neighbors,
+				Community: t.community(RoleCodeToR, pairIdx, globalToRIdx),
+			}
+			if t.config.Aggregate {
+				data.Aggregate = t.addrs.RackAggregate(t.rackSlot(pairIdx, torIdx), t.rackBlockSize())
+				data.AggregateSummaryOnly = t.config.AggregateSummaryOnly
+			}
+			if t.config.L2Racks() {
+				data.RackSubnet = t.addrs.RackAggregate(t.rackSlot(pairIdx, torIdx), t.rackBlockSize())
+			}
+			if t.config.StaticServers() {
+				data.ServerRoutes = t.staticServerRoutes(name, pairIdx, torIdx, globalToRIdx)
+			}
+			if err := t.addNodeConfig(name, "tor", data, false); err != nil {
 				return err
 			}
 		}
@@ -435,9 +690,9 @@ func (t *Topology) buildServers() error {
 			globalToRIdx := pairIdx*t.config.NumToRsPerLeafPair + torIdx
 
 			for srvIdx := 0; srvIdx < t.config.NumServersPerToR; srvIdx++ {
-				serverASN := ServerASN(serverNum)
+				serverASN := t.asn.ServerASN(serverNum)
 				name := fmt.Sprintf("server%d-as%d", serverNum, serverASN)
-				routerID := ServerRouterID(serverNum)
+				routerID := t.serverRouterID(pairIdx, torIdx, srvIdx, serverNum)
 
 				// ToR neighbor (peer info was set when ToRs were built)
 				peerIf := "tr0"
@@ -451,10 +706,24 @@ func (t *Topology) buildServers() error {
 					LocalLLA:     localLLA,
 					ImportFilter: "server_import",
 					ExportFilter: "server_export",
-					MaxPrefix:    100,
+					MaxPrefix:    t.config.ReceiveLimit(t.config.FabricPrefixes()),
 				}}
 
-				if err := t.addNodeConfig(name, routerID, "server", serverASN, neighbors, true); err != nil {
+				data := TemplateData{
+					RouterID:  routerID,
+					ASN:       serverASN,
+					Neighbors: neighbors,
+					Community: t.community(RoleCodeServer, pairIdx, globalToRIdx),
+				}
+				if t.config.Workloads() {
+					data.Workload = t.addrs.WorkloadSubnet(serverNum, t.config.WorkloadPrefixLen)
+				}
+				if t.config.L2Racks() {
+					data.RackSubnet = t.addrs.RackAggregate(t.rackSlot(pairIdx, torIdx), t.rackBlockSize())
+				}
+				if t.config.StaticServers() {
+					t.addStaticServerConfig(name, data, t.addrs.ToRRouterID(globalToRIdx))
+				} else if err := t.addNodeConfig(name, "server", data, true); err != nil {
 					return err
 				}
 				serverNum++
@@ -467,7 +736,7 @@ func (t *Topology) buildServers() error {
 func (t *Topology) buildRouters() error {
 	for rtIdx := 0; rtIdx < t.config.NumRouters; rtIdx++ {
 		name := fmt.Sprintf("router%d", rtIdx)
-		routerID := RouterRouterID(rtIdx)
+		routerID := t.addrs.RouterRouterID(rtIdx)
 		var neighbors []Neighbor
 
 		// Border Leaf neighbors (peer info was set when BLs were built)
@@ -483,7 +752,7 @@ func (t *Topology) buildRouters() error {
 				LocalLLA:     localLLA,
 				ImportFilter: "router_import",
 				ExportFilter: "router_export",
-				MaxPrefix:    1000,
+				MaxPrefix:    t.config.ReceiveLimit(t.config.FabricPrefixes()),
 			})
 		}
 
@@ -492,7 +761,16 @@ func (t *Topology) buildRouters() error {
 			t.addBridgeInterface(name, "eth0", ExternalBridgeName)
 		}
 
-		if err := t.addRouterNodeConfig(name, routerID, ASNRouter, neighbors, rtIdx); err != nil {
+		data := TemplateData{
+			RouterID:         routerID,
+			ASN:              t.asn.RouterASN(),
+			Neighbors:        neighbors,
+			Community:        t.community(RoleCodeRouter, -1, -1),
+			RPKI:             t.rpki,
+			ExternalRoutes:   t.config.Definition.ExternalPrefixes.Originated(name),
+			ExternalPrefixes: t.config.Definition.ExternalPrefixes.PrefixSet(),
+		}
+		if err := t.addRouterNodeConfig(name, data, rtIdx); err != nil {
 			return err
 		}
 	}
@@ -500,13 +778,24 @@ func (t *Topology) buildRouters() error {
 }
 
 // addRouterNodeConfig adds a router node configuration with optional external network settings.
-func (t *Topology) addRouterNodeConfig(name, routerID string, asn int, neighbors []Neighbor, routerIndex int) error {
+func (t *Topology) addRouterNodeConfig(name string, data TemplateData, routerIndex int) error {
 	// Generate BIRD config using template
-	data := TemplateData{
-		RouterID:  routerID,
-		ASN:       asn,
-		Neighbors: neighbors,
+	data.RoutingMode = t.config.RoutingMode
+	data.Addresses = t.addrs
+	if t.config.Workloads() {
+		data.Workloads = t.addrs.Workloads.Subnets(t.config.WorkloadPrefixLen)
 	}
+	data.RoutingPolicy = t.config.RoutingPolicy
+	data.SpineASNs = t.asn.SpineASNRange(t.config.NumSpines)
+	t.applySessionSettings(name, data.Neighbors)
+	data.EgressPrepend = t.config.Definition.Egress.ExportPrepend(name)
+	data.EgressMED = t.config.Definition.Egress.ExportMED(name)
+	data.Name = name
+	if t.config.MRTEnabled(name) {
+		data.MRTDir = MRTDir(name)
+		data.MRTPeriod = t.config.MRTPeriod
+	}
+	data.MergePaths = t.config.ECMP("router").MergePaths
 
 	birdConf, err := t.templates.Render("router", data)
 	if err != nil {
@@ -514,9 +803,17 @@ func (t *Topology) addRouterNodeConfig(name, routerID string, asn int, neighbors
 	}
 
 	t.birdConfigs[name] = birdConf
+	loopback := data.RouterID + "/32"
+	t.nodes = append(t.nodes, NodeInfo{
+		Name:      name,
+		Role:      "router",
+		ASN:       data.ASN,
+		RouterID:  data.RouterID,
+		Loopbacks: []string{loopback},
+	})
 
 	cmds := []Command{
-		{Cmd: fmt.Sprintf("ip addr add %s/32 dev lo", routerID)},
+		{Cmd: fmt.Sprintf("ip addr add %s dev lo", loopback)},
 	}
 
 	// Add MAC setting commands
@@ -527,10 +824,16 @@ func (t *Topology) addRouterNodeConfig(name, routerID string, asn int, neighbors
 	cmds = append(cmds,
 		Command{Cmd: "sysctl -w net.ipv4.ip_forward=1"},
 		Command{Cmd: "sysctl -w net.ipv6.conf.all.forwarding=1"},
+	)
+	cmds = append(cmds, t.config.ECMP("router").Sysctls()...)
+	cmds = append(cmds,
 		Command{Cmd: fmt.Sprintf("cp /tinet/%s.conf /etc/bird/bird.conf", name)},
 		Command{Cmd: "mkdir -p /run/bird"},
-		Command{Cmd: "bird -c /etc/bird/bird.conf"},
 	)
+	if data.MRTDir != "" {
+		cmds = append(cmds, Command{Cmd: "mkdir -p " + data.MRTDir})
+	}
+	cmds = append(cmds, Command{Cmd: "bird -c /etc/bird/bird.conf"})
 
 	// Add external network configuration if enabled
 	if t.config.ExternalNetwork {
@@ -546,13 +849,25 @@ func (t *Topology) addRouterNodeConfig(name, routerID string, asn int, neighbors
 	return nil
 }
 
-func (t *Topology) addNodeConfig(name, routerID, role string, asn int, neighbors []Neighbor, isServer bool) error {
+func (t *Topology) addNodeConfig(name, role string, data TemplateData, isServer bool) error {
 	// Generate BIRD config using template
-	data := TemplateData{
-		RouterID:  routerID,
-		ASN:       asn,
-		Neighbors: neighbors,
+	data.RoutingMode = t.config.RoutingMode
+	data.Addresses = t.addrs
+	if t.config.Workloads() {
+		data.Workloads = t.addrs.Workloads.Subnets(t.config.WorkloadPrefixLen)
 	}
+	data.RoutingPolicy = t.config.RoutingPolicy
+	data.SpineASNs = t.asn.SpineASNRange(t.config.NumSpines)
+	t.applySessionSettings(name, data.Neighbors)
+	data.EgressPrepend = t.config.Definition.Egress.ExportPrepend(name)
+	data.EgressMED = t.config.Definition.Egress.ExportMED(name)
+	data.Name = name
+	if t.config.MRTEnabled(name) {
+		data.MRTDir = MRTDir(name)
+		data.MRTPeriod = t.config.MRTPeriod
+	}
+	data.MergePaths = t.config.ECMP(role).MergePaths
+	data.Tenants = t.tenantData(name, data.Neighbors)
 
 	birdConf, err := t.templates.Render(role, data)
 	if err != nil {
@@ -560,27 +875,57 @@ func (t *Topology) addNodeConfig(name, routerID, role string, asn int, neighbors
 	}
 
 	t.birdConfigs[name] = birdConf
-
-	cmds := []Command{
-		{Cmd: fmt.Sprintf("ip addr add %s/32 dev lo", routerID)},
+	loopbacks := []string{data.RouterID + "/32"}
+	if isServer {
+		loopbacks = append(loopbacks, t.addrs.AnycastAddress()+"/32")
 	}
+	var workload, gateway string
+	if data.Workload != "" {
+		workload = subnetGateway(data.Workload)
+	}
+	if data.RackSubnet != "" {
+		gateway = subnetGateway(data.RackSubnet)
+	}
+	t.nodes = append(t.nodes, NodeInfo{
+		Name:      name,
+		Role:      role,
+		ASN:       data.ASN,
+		RouterID:  data.RouterID,
+		Loopbacks: loopbacks,
+		Workload:  workload,
+		Gateway:   gateway,
+	})
 
-	if isServer {
-		cmds = append(cmds, Command{Cmd: fmt.Sprintf("ip addr add %s/32 dev lo", AnycastAddress)})
+	var cmds []Command
+	for _, lo := range loopbacks {
+		cmds = append(cmds, Command{Cmd: fmt.Sprintf("ip addr add %s dev lo", lo)})
+	}
+	if workload != "" {
+		cmds = append(cmds, workloadCmds(workload)...)
 	}
 
 	// Add MAC setting commands
 	for _, macCmd := range t.macCmds[name] {
 		cmds = append(cmds, Command{Cmd: macCmd})
 	}
+	if gateway != "" {
+		cmds = append(cmds, t.rackBridgeCmds(gateway)...)
+	}
+	cmds = append(cmds, tenantCmds(data.Tenants)...)
 
 	cmds = append(cmds,
 		Command{Cmd: "sysctl -w net.ipv4.ip_forward=1"},
 		Command{Cmd: "sysctl -w net.ipv6.conf.all.forwarding=1"},
+	)
+	cmds = append(cmds, t.config.ECMP(role).Sysctls()...)
+	cmds = append(cmds,
 		Command{Cmd: fmt.Sprintf("cp /tinet/%s.conf /etc/bird/bird.conf", name)},
 		Command{Cmd: "mkdir -p /run/bird"},
-		Command{Cmd: "bird -c /etc/bird/bird.conf"},
 	)
+	if data.MRTDir != "" {
+		cmds = append(cmds, Command{Cmd: "mkdir -p " + data.MRTDir})
+	}
+	cmds = append(cmds, Command{Cmd: "bird -c /etc/bird/bird.conf"})
 
 	t.nodeConfigs = append(t.nodeConfigs, NodeConfig{Name: name, Cmds: cmds})
 	return nil
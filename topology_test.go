@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -28,11 +30,15 @@ protocol bgp {{ .Name }} {
 
 func TestRouterIDUniqueness(t *testing.T) {
 	cfg := DefaultConfig()
+	addrs, err := NewAddressPlan(AddressBlocks{})
+	if err != nil {
+		t.Fatal(err)
+	}
 	ids := make(map[string]string)
 
 	// Spines
 	for i := 0; i < cfg.NumSpines; i++ {
-		id := SpineRouterID(i)
+		id := addrs.SpineRouterID(i)
 		name := "spine" + string(rune('0'+i))
 		if existing, ok := ids[id]; ok {
 			t.Errorf("Duplicate router ID %s: %s and %s", id, existing, name)
@@ -43,7 +49,7 @@ func TestRouterIDUniqueness(t *testing.T) {
 	// Leafs
 	for p := 0; p < cfg.NumLeafPairs; p++ {
 		for l := 1; l <= 2; l++ {
-			id := LeafRouterID(p, l)
+			id := addrs.LeafRouterID(p, l)
 			name := "leaf"
 			if existing, ok := ids[id]; ok {
 				t.Errorf("Duplicate router ID %s: %s and %s", id, existing, name)
@@ -54,7 +60,7 @@ func TestRouterIDUniqueness(t *testing.T) {
 
 	// ToRs
 	for i := 0; i < cfg.TotalToRs(); i++ {
-		id := ToRRouterID(i)
+		id := addrs.ToRRouterID(i)
 		name := "tor"
 		if existing, ok := ids[id]; ok {
 			t.Errorf("Duplicate router ID %s: %s and %s", id, existing, name)
@@ -64,7 +70,7 @@ func TestRouterIDUniqueness(t *testing.T) {
 
 	// Border Leafs
 	for i := 0; i < cfg.NumBorderLeafs; i++ {
-		id := BorderLeafRouterID(i)
+		id := addrs.BorderLeafRouterID(i)
 		name := "bl"
 		if existing, ok := ids[id]; ok {
 			t.Errorf("Duplicate router ID %s: %s and %s", id, existing, name)
@@ -74,7 +80,7 @@ func TestRouterIDUniqueness(t *testing.T) {
 
 	// Routers
 	for i := 0; i < cfg.NumRouters; i++ {
-		id := RouterRouterID(i)
+		id := addrs.RouterRouterID(i)
 		name := "router"
 		if existing, ok := ids[id]; ok {
 			t.Errorf("Duplicate router ID %s: %s and %s", id, existing, name)
@@ -84,7 +90,7 @@ func TestRouterIDUniqueness(t *testing.T) {
 
 	// Servers
 	for i := 0; i < cfg.TotalServers(); i++ {
-		id := ServerRouterID(i)
+		id := addrs.ServerRouterID(i)
 		name := "server"
 		if existing, ok := ids[id]; ok {
 			t.Errorf("Duplicate router ID %s: %s and %s", id, existing, name)
@@ -268,3 +274,772 @@ func TestMACUniqueness(t *testing.T) {
 		}
 	}
 }
+
+// repoTemplates loads the BIRD templates shipped with the repository.
+func repoTemplates(t *testing.T) *Templates {
+	t.Helper()
+	templates, err := LoadTemplates("templates.yaml")
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	return templates
+}
+
+// buildTestTopology builds the default topology, changed by mutate, with
+// the repository templates.
+func buildTestTopology(t *testing.T, mutate func(*Config)) (*Topology, Spec) {
+	t.Helper()
+	cfg := DefaultConfig()
+	if mutate != nil {
+		mutate(&cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	topo := NewTopology(cfg, repoTemplates(t))
+	spec, err := topo.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	return topo, spec
+}
+
+// TestRenderedConfigs checks the statements each option adds to the BIRD
+// configs and node commands.
+func TestRenderedConfigs(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		configs map[string][]string    // Lines in the BIRD config of a node ("*" for all nodes)
+		absent  map[string][]string    // Text not in the BIRD config of a node ("*" for all nodes)
+		filters map[[2]string][]string // Statements of a filter, keyed by {node, filter}
+		session map[[2]string][]string // Statements of a BGP session, keyed by {node, protocol}
+		noSess  map[[2]string][]string // Text not in a BGP session, keyed by {node, protocol}
+		noBird  []string               // Nodes without a BIRD config
+		cmds    map[string][]string    // Commands of a node
+		noCmds  map[string][]string    // Commands a node must not run
+	}{
+		{
+			name:   "prefix policy",
+			absent: map[string][]string{"*": {"bgp_large_community", "define ROLE"}},
+			filters: map[[2]string][]string{
+				{"leaf1-as4200001000", "leaf_import_from_tor"}: {
+					"if net ~ [ 10.255.2.0/23{23,32} ] then accept;",
+					"if net ~ [ 10.0.0.0/16{16,32} ] then accept;",
+				},
+				{"bl0", "bl_import_from_router"}: {
+					"if net ~ [ 10.255.255.0/24{32,32} ] then accept;",
+					"if net = 0.0.0.0/0 then accept;",
+				},
+			},
+		},
+		{
+			name:   "community policy",
+			mutate: func(c *Config) { c.RoutingPolicy = RoutingPolicyCommunity },
+			configs: map[string][]string{
+				"tor1-as4200010001": {"define ROLE = 4;", "define POD = 0;", "define RACK = 1;", "tag_origin();"},
+				"spine0":            {"define ROLE = 1;"},
+			},
+			absent: map[string][]string{"spine0": {"define POD", "define RACK"}},
+			filters: map[[2]string][]string{
+				{"leaf1-as4200001000", "leaf_import_from_tor"}: {
+					"if (FABRIC, C_POD, POD) !~ bgp_large_community then reject;",
+					"if bgp_large_community ~ [(FABRIC, C_ROLE, ROLE_TOR), (FABRIC, C_ROLE, ROLE_SERVER)] then accept;",
+				},
+				{"bl0", "bl_export_to_router"}: {
+					"if (FABRIC, C_ROLE, ROLE_ROUTER) ~ bgp_large_community then reject;",
+				},
+			},
+		},
+		{
+			name: "pod and rack aggregates",
+			mutate: func(c *Config) {
+				c.Aggregate = true
+				c.AggregateSummaryOnly = true
+			},
+			configs: map[string][]string{
+				"leaf1-as4200001000": {"route 10.0.0.0/29 blackhole;"},
+				"tor1-as4200010001":  {"route 10.0.0.4/30 blackhole;"},
+			},
+			absent: map[string][]string{"spine0": {"static_aggregate"}},
+			filters: map[[2]string][]string{
+				{"leaf1-as4200001000", "leaf_export_to_spine"}: {
+					"if net = 10.0.0.0/29 then accept;\n        if net ~ [ 10.0.0.0/29+ ] then reject;",
+				},
+				{"tor1-as4200010001", "tor_export_to_leaf"}: {
+					"if net = 10.0.0.4/30 then accept;\n        if net ~ [ 10.0.0.4/30+ ] then reject;",
+				},
+			},
+		},
+		{
+			name:   "aggregates without summary only",
+			mutate: func(c *Config) { c.Aggregate = true },
+			filters: map[[2]string][]string{
+				{"leaf1-as4200001000", "leaf_export_to_spine"}: {"if net = 10.0.0.0/29 then accept;"},
+			},
+			absent: map[string][]string{"*": {"+ ] then reject;"}},
+		},
+		{
+			name: "ASN reuse",
+			mutate: func(c *Config) {
+				c.NumLeafPairs = 2
+				c.ASNScheme = ASNSchemeReuse
+			},
+			// tor0 (pair 0) and tor2 (pair 1) share the same ASN
+			configs: map[string][]string{
+				"tor0-as4200010000": {"allow local as 1;"},
+				"tor2-as4200010000": {"allow local as 1;"},
+			},
+			absent: map[string][]string{
+				"spine0":             {"allow local as"},
+				"leaf1-as4200001000": {"allow local as"},
+				"bl0":                {"allow local as"},
+			},
+		},
+		{
+			name:   "unique Spine ASNs",
+			mutate: func(c *Config) { c.ASNScheme = ASNSchemeUnique },
+			filters: map[[2]string][]string{
+				{"leaf1-as4200001000", "leaf_export_to_spine"}: {"if filter(bgp_path, [ 4200000100..4200000101 ]).len > 0 then reject;"},
+				{"bl0", "bl_export_to_spine"}:                  {"if filter(bgp_path, [ 4200000100..4200000101 ]).len > 0 then reject;"},
+			},
+			absent: map[string][]string{"tor1-as4200010001": {"bgp_path"}},
+		},
+		{
+			name: "session authentication",
+			mutate: func(c *Config) {
+				c.BGPAuth = BGPAuthTier
+				c.BGPAuthSeed = "lab"
+			},
+			session: map[[2]string][]string{
+				{"spine0", "leaf1_as4200001000"}: {"password \""},
+				{"tor1-as4200010001", "server2"}: {"password \""},
+			},
+		},
+		{
+			name:    "TTL security on all sessions",
+			mutate:  func(c *Config) { c.TTLSecurity = true },
+			configs: map[string][]string{"*": {"ttl security on;"}},
+		},
+		{
+			name: "TTL security per tier and link",
+			mutate: func(c *Config) {
+				c.Definition.TTLSecurity = TTLSecurityDefinition{
+					Tiers: map[string]bool{"spine-leaf": true},
+					Links: map[string]bool{"spine0#lf0": false},
+				}
+			},
+			// sp0 is the link disabled from the spine end; sp1 follows the tier
+			session: map[[2]string][]string{
+				{"leaf1-as4200001000", "spine1"}: {"ttl security on;"},
+			},
+			noSess: map[[2]string][]string{
+				{"leaf1-as4200001000", "spine0"}: {"ttl security"},
+				{"spine0", "leaf1_as4200001000"}: {"ttl security"},
+				{"leaf1-as4200001000", "tor0"}:   {"ttl security"},
+			},
+		},
+		{
+			name: "TTL security disabled on a link",
+			mutate: func(c *Config) {
+				c.TTLSecurity = true
+				c.Definition.TTLSecurity.Links = map[string]bool{"leaf1-as4200001000#tr0": false}
+			},
+			session: map[[2]string][]string{
+				{"leaf1-as4200001000", "tor1"}: {"ttl security on;"},
+			},
+			noSess: map[[2]string][]string{
+				{"leaf1-as4200001000", "tor0"}: {"ttl security"},
+				{"tor0-as4200010000", "leaf1"}: {"ttl security"},
+			},
+		},
+		{
+			name: "timer profiles",
+			mutate: func(c *Config) {
+				c.Definition.Timers = TimerDefinition{
+					Tiers: map[string]string{"spine-leaf": "aggressive"},
+					Links: map[string]string{"spine0#lf0": "relaxed"},
+				}
+			},
+			// sp0 is the link assigned from the spine end; sp1 falls back to the tier
+			configs: map[string][]string{"leaf1-as4200001000": {
+				"interface \"sp0\" {\n                min rx interval 300 ms;",
+				"interface \"sp1\" {\n                min rx interval 50 ms;",
+				"interface \"tr0\" {\n                min rx interval 100 ms;",
+			}},
+			session: map[[2]string][]string{
+				{"leaf1-as4200001000", "spine0"}: {"hold time 240;"},
+				{"leaf1-as4200001000", "spine1"}: {"hold time 9;"},
+				{"spine0", "leaf1_as4200001000"}: {"hold time 240;"},
+			},
+		},
+		{
+			name:   "egress prepend on backups",
+			mutate: egressBackup(EgressPrepend),
+			filters: map[[2]string][]string{
+				{"bl1", "bl_export_to_spine"}: {"if net = 0.0.0.0/0 then {\n                bgp_path.prepend(LOCAL_AS);"},
+				{"router1", "router_export"}:  {"bgp_path.prepend(LOCAL_AS);"},
+			},
+			absent: map[string][]string{"bl0": {"prepend"}, "router0": {"prepend"}},
+		},
+		{
+			name:   "egress local preference on backups",
+			mutate: egressBackup(EgressLocalPref),
+			filters: map[[2]string][]string{
+				{"spine0", "spine_import"}:       {`if proto = "bl1" && net = 0.0.0.0/0 then bgp_local_pref = 50;`},
+				{"bl0", "bl_import_from_router"}: {`if proto = "router1" && net = 0.0.0.0/0 then bgp_local_pref = 50;`},
+			},
+			absent: map[string][]string{"spine0": {`proto = "bl0"`}, "bl0": {`proto = "router0"`}},
+		},
+		{
+			name:   "egress MED on backups",
+			mutate: egressBackup(EgressMED),
+			filters: map[[2]string][]string{
+				{"router1", "router_export"}: {"if net = 0.0.0.0/0 then bgp_med = 100;"},
+			},
+			absent: map[string][]string{"bl0": {"bgp_med"}, "router0": {"bgp_med"}},
+		},
+		{
+			name: "TE policies",
+			mutate: func(c *Config) {
+				c.Definition.Policies = TEPolicies{
+					{Node: "spine1", Peer: "leaf*", Prepend: 2},
+					{Node: "leaf*", Peer: "spine0", Direction: TEImport, LocalPref: 80},
+				}
+			},
+			configs: map[string][]string{
+				"spine1": {"export filter te_export_leaf1_as4200001000;"},
+				"leaf1-as4200001000": {
+					"import filter te_import_spine0;",
+					"import filter leaf_import_from_spine;",
+				},
+			},
+			absent: map[string][]string{"spine0": {"te_export"}},
+			filters: map[[2]string][]string{
+				{"spine1", "te_export_leaf1_as4200001000"}: {"bgp_path.prepend(LOCAL_AS);\n        bgp_path.prepend(LOCAL_AS);\n        if net"},
+				{"leaf1-as4200001000", "te_import_spine0"}: {"bgp_local_pref = 80;"},
+			},
+		},
+		{
+			name: "tenants with unique Spine ASNs",
+			mutate: func(c *Config) {
+				c.NumLeafPairs = 2
+				c.ASNScheme = ASNSchemeUnique
+				c.Definition.Tenants = Tenants{{Name: "red", ID: 10, Prefix: "172.16.0.0/24", Servers: []string{"server0-*", "server5-*"}}}
+			},
+			configs: map[string][]string{"leaf1-as4200001000": {
+				"protocol bgp red_spine0 {",
+				"export filter tenant_red_to_spine;",
+				"export filter tenant_red;",
+			}},
+			absent: map[string][]string{
+				"spine0":            {"tenant_red_to_spine"},
+				"tor0-as4200010000": {"tenant_red_to_spine"},
+			},
+			filters: map[[2]string][]string{
+				{"leaf1-as4200001000", "tenant_red_to_spine"}: {"if filter(bgp_path, [ 4200000100..4200000101 ]).len > 0 then reject;"},
+			},
+		},
+		{
+			name: "ECMP",
+			mutate: func(c *Config) {
+				c.ECMPHash = ECMPHashL4
+				c.ECMPMergePaths = 8
+				c.Definition.ECMP.Roles = map[string]ECMPSettings{
+					"spine": {Hash: ECMPHashL3, MergePaths: 4},
+					"tor":   {Hash: ECMPHashInner},
+				}
+			},
+			configs: map[string][]string{
+				"spine0":             {"merge paths yes limit 4;"},
+				"tor0-as4200010000":  {"merge paths yes limit 8;"},
+				"leaf1-as4200001000": {"merge paths yes limit 8;"},
+			},
+			cmds: map[string][]string{
+				"spine0":               {"sysctl -w net.ipv6.fib_multipath_hash_policy=0"},
+				"tor0-as4200010000":    {"sysctl -w net.ipv6.fib_multipath_hash_policy=2"},
+				"server0-as4200100000": {"sysctl -w net.ipv6.fib_multipath_hash_policy=1"},
+				"router0":              {"sysctl -w net.ipv6.fib_multipath_hash_policy=1"},
+			},
+		},
+		{
+			name: "MRT",
+			mutate: func(c *Config) {
+				c.MRTNodes = "spine*, bl0"
+				c.MRTPeriod = 30
+			},
+			configs: map[string][]string{
+				"spine1": {
+					`mrtdump "/tinet/mrt/spine1/messages.mrt";`,
+					"mrtdump protocols { states, messages };",
+					`filename "/tinet/mrt/spine1/master4-%Y%m%d-%H%M%S.mrt";`,
+					"period 30;",
+				},
+				"bl0": {"protocol mrt"},
+			},
+			absent: map[string][]string{"leaf1-as4200001000": {"mrt"}},
+			cmds:   map[string][]string{"spine0": {"mkdir -p /tinet/mrt/spine0"}},
+		},
+		{
+			name: "numbered links",
+			mutate: func(c *Config) {
+				c.NumberedLinks = "spine-leaf"
+				c.Definition.Addresses.Links6 = "fd00:0:0:ff::/64"
+			},
+			// spine0 lf0 is the first numbered link; Spine-BL sessions stay unnumbered
+			configs: map[string][]string{
+				"spine0": {
+					"neighbor 10.254.0.1 as 4200001000;",
+					"local 10.254.0.0 as LOCAL_AS;",
+					"neighbor fe80::",
+				},
+				"leaf1-as4200001000": {"neighbor 10.254.0.0 as 4200000000;"},
+			},
+			cmds: map[string][]string{"spine0": {
+				"ip addr add 10.254.0.0/31 dev lf0",
+				"ip -6 addr add fd00:0:0:ff::/127 dev lf0",
+			}},
+		},
+		{
+			name:    "workloads",
+			mutate:  func(c *Config) { c.WorkloadPrefixLen = 28 },
+			configs: map[string][]string{"server1-as4200100001": {`interface "wl0";`}},
+			filters: map[[2]string][]string{
+				{"leaf1-as4200001000", "leaf_import_from_tor"}: {"if net ~ [ 10.200.0.0/16{28,28} ] then accept;"},
+				{"spine0", "spine_import"}:                     {"if net ~ [ 10.200.0.0/16{28,28} ] then accept;"},
+			},
+			cmds: map[string][]string{"server1-as4200100001": {
+				"ip link add wl0 type bridge",
+				"ip addr add 10.200.0.17/28 dev wl0",
+			}},
+		},
+		{
+			name: "static servers",
+			mutate: func(c *Config) {
+				c.ServerMode = ServerModeStatic
+				c.WorkloadPrefixLen = 28
+			},
+			configs: map[string][]string{"tor0-as4200010000": {
+				`route 10.0.0.1/32 via "sv0";`,
+				"route 10.200.0.16/28 via 10.0.0.2%sv1 onlink;",
+			}},
+			absent: map[string][]string{"tor0-as4200010000": {"protocol bgp server"}},
+			noBird: []string{"server0-as4200100000"},
+			cmds: map[string][]string{"server0-as4200100000": {
+				"ip addr add 10.0.0.1/32 dev lo",
+				"ip addr add 10.200.0.1/28 dev wl0",
+				"ip route add default via 10.255.2.1 dev tr0 onlink",
+			}},
+			noCmds: map[string][]string{"server0-as4200100000": {
+				"ip addr add 10.100.0.1/32 dev lo",
+				"bird -c /etc/bird/bird.conf",
+			}},
+		},
+		{
+			name: "static servers on numbered links",
+			mutate: func(c *Config) {
+				c.ServerMode = ServerModeStatic
+				c.NumberedLinks = "tor-server"
+			},
+			configs: map[string][]string{"tor0-as4200010000": {"route 10.0.0.1/32 via 10.254.96.1;"}},
+		},
+		{
+			name: "L2 racks",
+			mutate: func(c *Config) {
+				c.RackMode = RackModeL2
+				c.NumServersPerToR = 6
+			},
+			// 6 servers + gateway, network and broadcast need a /28
+			configs: map[string][]string{"tor1-as4200010001": {
+				`interface "br0";`,
+				"if net = 10.0.0.16/28 then accept;",
+			}},
+			noBird: []string{"server6-as4200100006"},
+			cmds: map[string][]string{
+				"tor1-as4200010001": {
+					"ip link set dev br0 address 02:00:00:00:00:00",
+					"ip addr add 10.0.0.17/28 dev br0",
+					"ip link set dev sv5 master br0",
+				},
+				"server6-as4200100006": {
+					"ip addr add 10.0.0.18/28 dev tr0",
+					"ip route add default via 10.0.0.17",
+				},
+			},
+		},
+		{
+			name: "drain with graceful shutdown",
+			mutate: func(c *Config) {
+				c.DrainNode = "spine1"
+				c.DrainMethod = DrainGracefulShutdown
+			},
+			absent: map[string][]string{"spine0": {"te_export"}},
+			filters: map[[2]string][]string{
+				{"spine1", "te_export_leaf1_as4200001000"}:       {"bgp_community.add((65535, 0));"},
+				{"leaf1-as4200001000", "leaf_import_from_spine"}: {"if (65535, 0) ~ bgp_community then bgp_local_pref = 0;"},
+			},
+		},
+		{
+			name: "community external prefixes",
+			mutate: func(c *Config) {
+				c.RoutingPolicy = RoutingPolicyCommunity
+				c.Definition.ExternalPrefixes = ExternalPrefixes{{Router: "router0", Prefix: "192.0.2.0/24"}}
+			},
+			configs: map[string][]string{
+				"router0": {"if net != 0.0.0.0/0 then bgp_large_community.add((FABRIC, C_EXTERNAL, 1));"},
+				"*":       {"define C_EXTERNAL = 4;"},
+			},
+			filters: map[[2]string][]string{
+				{"bl0", "bl_export_to_spine"}: {"if bgp_large_community ~ [(FABRIC, C_EXTERNAL, *)] then reject;"},
+			},
+		},
+		{
+			name: "receive limits with external prefixes",
+			mutate: func(c *Config) {
+				c.NumRouters = 2
+				c.Definition.ExternalPrefixes = ExternalPrefixes{
+					{Router: "router0", Prefix: "192.0.2.0/24"},
+					{Router: "router0", Prefix: "203.0.113.0/24"},
+				}
+			},
+			// Edge: 1 Border Leaf + 2 Router loopbacks + default route, plus
+			// the minimum headroom of 10. Only router0 sends external prefixes.
+			session: map[[2]string][]string{
+				{"bl0", "router0"}: {"receive limit 16 action warn;"},
+				{"bl0", "router1"}: {"receive limit 14 action warn;"},
+				{"spine0", "bl0"}:  {"receive limit 14 action warn;"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topo, spec := buildTestTopology(t, tt.mutate)
+			configs := topo.GetBirdConfigs()
+			cmds := nodeCmds(spec)
+			for node, wants := range tt.configs {
+				for _, want := range wants {
+					for _, name := range matchNodes(configs, node) {
+						if !strings.Contains(configs[name], want) {
+							t.Errorf("%s config missing %q", name, want)
+						}
+					}
+				}
+			}
+			for node, unwanted := range tt.absent {
+				for _, u := range unwanted {
+					for _, name := range matchNodes(configs, node) {
+						if strings.Contains(configs[name], u) {
+							t.Errorf("%s config contains %q", name, u)
+						}
+					}
+				}
+			}
+			for key, wants := range tt.filters {
+				body, ok := filterBody(configs[key[0]], key[1])
+				if !ok {
+					t.Errorf("%s config missing filter %s", key[0], key[1])
+					continue
+				}
+				for _, want := range wants {
+					if !strings.Contains(body, want) {
+						t.Errorf("%s filter %s missing %q", key[0], key[1], want)
+					}
+				}
+			}
+			for key, wants := range tt.session {
+				body, ok := sessionBody(configs[key[0]], key[1])
+				if !ok {
+					t.Errorf("%s config missing session %s", key[0], key[1])
+					continue
+				}
+				for _, want := range wants {
+					if !strings.Contains(body, want) {
+						t.Errorf("%s session %s missing %q", key[0], key[1], want)
+					}
+				}
+			}
+			for key, unwanted := range tt.noSess {
+				body, ok := sessionBody(configs[key[0]], key[1])
+				if !ok {
+					t.Errorf("%s config missing session %s", key[0], key[1])
+					continue
+				}
+				for _, u := range unwanted {
+					if strings.Contains(body, u) {
+						t.Errorf("%s session %s contains %q", key[0], key[1], u)
+					}
+				}
+			}
+			for _, name := range tt.noBird {
+				if _, ok := configs[name]; ok {
+					t.Errorf("%s has a BIRD config", name)
+				}
+			}
+			for node, wants := range tt.cmds {
+				for _, want := range wants {
+					if !slices.Contains(cmds[node], want) {
+						t.Errorf("%s missing command %q", node, want)
+					}
+				}
+			}
+			for node, unwanted := range tt.noCmds {
+				for _, u := range unwanted {
+					if slices.Contains(cmds[node], u) {
+						t.Errorf("%s has unexpected command %q", node, u)
+					}
+				}
+			}
+		})
+	}
+}
+
+// egressBackup returns a mutation making bl1 and router1 egress backups.
+func egressBackup(method string) func(*Config) {
+	return func(c *Config) {
+		c.NumBorderLeafs = 2
+		c.NumRouters = 2
+		c.Definition.Egress = EgressDefinition{Method: method, Backup: []string{"bl1", "router1"}}
+	}
+}
+
+// matchNodes returns node, or all nodes with a BIRD config for "*".
+// nodeCmds returns the commands of each node.
+func nodeCmds(spec Spec) map[string][]string {
+	cmds := make(map[string][]string)
+	for _, nc := range spec.NodeConfigs {
+		for _, c := range nc.Cmds {
+			cmds[nc.Name] = append(cmds[nc.Name], c.Cmd)
+		}
+	}
+	return cmds
+}
+
+func matchNodes(configs map[string]string, node string) []string {
+	if node != "*" {
+		return []string{node}
+	}
+	var names []string
+	for name := range configs {
+		names = append(names, name)
+	}
+	return names
+}
+
+// filterBody returns the statements of a named filter in a BIRD config.
+func filterBody(conf, name string) (string, bool) {
+	return blockBody(conf, "filter "+name)
+}
+
+// sessionBody returns the statements of a BGP session in a BIRD config.
+func sessionBody(conf, name string) (string, bool) {
+	return blockBody(conf, "protocol bgp "+name)
+}
+
+// blockBody returns the statements of a top-level block in a BIRD config.
+func blockBody(conf, header string) (string, bool) {
+	_, body, ok := strings.Cut(conf, "\n"+header+" {\n")
+	if !ok {
+		return "", false
+	}
+	body, _, ok = strings.Cut(body, "\n}")
+	return body, ok
+}
+
+func TestASNReuseWarning(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ASNScheme = ASNSchemeReuse
+	if len(cfg.Warnings()) == 0 {
+		t.Errorf("reuse scheme should produce a warning")
+	}
+}
+
+// expectInvalid checks that each change of the default config is rejected,
+// by Validate or by Build.
+func expectInvalid(t *testing.T, cases map[string]func(*Config)) {
+	t.Helper()
+	for name, mutate := range cases {
+		cfg := DefaultConfig()
+		mutate(&cfg)
+		if err := cfg.Validate(); err != nil {
+			continue
+		}
+		if _, err := NewTopology(cfg, repoTemplates(t)).Build(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestInvalidConfigs(t *testing.T) {
+	expectInvalid(t, map[string]func(*Config){
+		"TTL security tier key": func(c *Config) {
+			c.Definition.TTLSecurity.Tiers = map[string]bool{"leaf-spine": true}
+		},
+		"unmatched TTL security link": func(c *Config) {
+			c.Definition.TTLSecurity.Links = map[string]bool{"spine0#lf9": true}
+		},
+		"conflicting TTL security link ends": func(c *Config) {
+			c.Definition.TTLSecurity.Links = map[string]bool{"spine0#lf0": true, "leaf1-as4200001000#sp0": false}
+		},
+		"unknown timer profile": func(c *Config) { c.TimerProfile = "fast" },
+		"unknown egress method": func(c *Config) {
+			c.Definition.Egress = EgressDefinition{Method: "weight", Backup: []string{"bl0"}}
+		},
+		"egress backup not an exit": func(c *Config) {
+			c.Definition.Egress = EgressDefinition{Method: EgressMED, Backup: []string{"spine0"}}
+		},
+		"egress backup does not exist": func(c *Config) {
+			c.Definition.Egress = EgressDefinition{Method: EgressMED, Backup: []string{"bl3"}}
+		},
+		"TE local pref on export": func(c *Config) {
+			c.Definition.Policies = TEPolicies{{Node: "spine1", Peer: "leaf*", Direction: TEExport, LocalPref: 80}}
+		},
+		"TE prepend on import": func(c *Config) {
+			c.Definition.Policies = TEPolicies{{Node: "spine1", Peer: "leaf*", Direction: TEImport, Prepend: 1}}
+		},
+		"TE policy without action": func(c *Config) {
+			c.Definition.Policies = TEPolicies{{Node: "spine1", Peer: "leaf*"}}
+		},
+		"TE bad glob": func(c *Config) {
+			c.Definition.Policies = TEPolicies{{Node: "spine[", Peer: "leaf*", MED: 10}}
+		},
+		"TE policy matching no session": func(c *Config) {
+			c.Definition.Policies = TEPolicies{{Node: "spine9", Peer: "*", MED: 10}}
+		},
+		"zero drain prepends": func(c *Config) {
+			c.DrainNode = "spine1"
+			c.DrainMethod = DrainPrepend
+			c.DrainPrepend = 0
+		},
+		"community policy with OSPF": func(c *Config) {
+			c.RoutingMode = RoutingModeOSPF
+			c.RoutingPolicy = RoutingPolicyCommunity
+		},
+		"ECMP unknown role": func(c *Config) {
+			c.Definition.ECMP.Roles = map[string]ECMPSettings{"core": {Hash: ECMPHashL3}}
+		},
+		"ECMP unknown hash policy":  func(c *Config) { c.ECMPHash = "l5" },
+		"MRT glob matching no node": func(c *Config) { c.MRTNodes = "spine9" },
+		"numbered unknown tier":     func(c *Config) { c.NumberedLinks = "spine-tor" },
+		"numbered OSPF": func(c *Config) {
+			c.NumberedLinks = NumberedAll
+			c.RoutingMode = RoutingModeOSPF
+		},
+		"numbered links beyond the slot fields": func(c *Config) {
+			c.NumberedLinks = "tor-server"
+			c.NumServersPerToR = 33
+		},
+		"numbered IPv4 block too small": func(c *Config) {
+			c.NumberedLinks = NumberedAll
+			c.Definition.Addresses.Links = "10.254.0.0/30"
+		},
+		"numbered IPv6 block too small": func(c *Config) {
+			c.NumberedLinks = NumberedAll
+			c.Definition.Addresses.Links6 = "fd00::/126"
+		},
+		"numbered IPv6 block is IPv4": func(c *Config) { c.Definition.Addresses.Links6 = "10.254.0.0/16" },
+		"workload prefix too short":   func(c *Config) { c.WorkloadPrefixLen = 16 },
+		"workload prefix too long":    func(c *Config) { c.WorkloadPrefixLen = 31 },
+		"workload block too small": func(c *Config) {
+			c.WorkloadPrefixLen = 30
+			c.Definition.Addresses.Workloads = "10.200.0.0/29"
+		},
+		"workload block overlaps": func(c *Config) { c.Definition.Addresses.Workloads = "10.0.0.0/8" },
+		"unknown server mode":     func(c *Config) { c.ServerMode = "none" },
+		"static servers in tenant": func(c *Config) {
+			c.ServerMode = ServerModeStatic
+			c.Definition.Tenants = Tenants{{Name: "red", ID: 10, Prefix: "172.16.0.0/24", Servers: []string{"*"}}}
+		},
+		"unknown rack mode":        func(c *Config) { c.RackMode = "l4" },
+		"L2 racks with aggregates": func(c *Config) { c.RackMode = RackModeL2; c.Aggregate = true },
+		"L2 racks numbered":        func(c *Config) { c.RackMode = RackModeL2; c.NumberedLinks = NumberedAll },
+		"L2 racks server block too small": func(c *Config) {
+			c.RackMode = RackModeL2
+			c.Definition.Addresses.Server = "10.0.0.0/29"
+		},
+		"timer tier key": func(c *Config) {
+			c.Definition.Timers.Tiers = map[string]string{"leaf-spine": "relaxed"}
+		},
+		"unmatched timer link": func(c *Config) {
+			c.Definition.Timers.Links = map[string]string{"spine0#lf9": "relaxed"}
+		},
+		"conflicting timer link profiles": func(c *Config) {
+			c.Definition.Timers.Links = map[string]string{"spine0#lf0": "relaxed", "leaf1-as4200001000#sp0": "aggressive"}
+		},
+	})
+}
+
+func TestReceiveLimitsScale(t *testing.T) {
+	topo, _ := buildTestTopology(t, func(c *Config) {
+		c.NumLeafPairs = 20
+		c.NumToRsPerLeafPair = 4
+		c.NumServersPerToR = 48
+		c.ReceiveLimitAction = ReceiveLimitBlock
+	})
+	cfg := topo.config
+
+	// One loopback per node plus the anycast address and the default route
+	if got, want := cfg.FabricPrefixes(), len(topo.GetNodes())+2; got != want {
+		t.Errorf("FabricPrefixes = %d, want %d", got, want)
+	}
+
+	limit := fmt.Sprintf("receive limit %d action block;", cfg.ReceiveLimit(cfg.FabricPrefixes()))
+	if conf := topo.GetBirdConfigs()["tor0-as4200010000"]; !strings.Contains(conf, limit) {
+		t.Errorf("tor0 config missing %q", limit)
+	}
+	if cfg.ReceiveLimit(cfg.FabricPrefixes()) <= 1000 {
+		t.Errorf("fabric receive limit %d does not exceed the old hardcoded 1000", cfg.ReceiveLimit(cfg.FabricPrefixes()))
+	}
+}
+
+func TestDrainSessions(t *testing.T) {
+	topo, _ := buildTestTopology(t, func(c *Config) {
+		c.DrainNode = "spine1"
+		c.DrainMethod = DrainGracefulShutdown
+	})
+
+	// Every session of the drained node gets its own export filter
+	spine := topo.GetBirdConfigs()["spine1"]
+	if n := strings.Count(spine, "export filter te_export_"); n != topo.SessionCount("spine1") {
+		t.Errorf("spine1 has %d drained exports, want %d", n, topo.SessionCount("spine1"))
+	}
+	if n := strings.Count(spine, "filter te_export_"); n != 2*topo.SessionCount("spine1") {
+		t.Errorf("spine1 has %d drain filters and exports, want %d", n, 2*topo.SessionCount("spine1"))
+	}
+}
+
+func TestRoutingModes(t *testing.T) {
+	tests := []struct {
+		mode       string
+		protocol   string
+		unnumbered bool // IPv4 next hops on the unnumbered links
+	}{
+		{RoutingModeOSPF, "protocol ospf v3 underlay {", true},
+		{RoutingModeBabel, "protocol babel underlay {", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			topo, spec := buildTestTopology(t, func(c *Config) { c.RoutingMode = tt.mode })
+			for name, conf := range topo.GetBirdConfigs() {
+				if strings.Contains(conf, "protocol bgp") {
+					t.Errorf("%s config has BGP sessions", name)
+				}
+				if !strings.Contains(conf, tt.protocol) {
+					t.Errorf("%s config missing %q", name, tt.protocol)
+				}
+			}
+			if !strings.Contains(topo.GetBirdConfigs()["spine0"], `interface "lf1"`) {
+				t.Errorf("spine0 missing interface lf1")
+			}
+
+			cmds := nodeCmds(spec)["spine0"]
+			addr := slices.Index(cmds, "ip addr add 10.255.0.1/32 peer 10.255.1.1/32 dev lf0")
+			if got := addr >= 0; got != tt.unnumbered {
+				t.Errorf("unnumbered address on spine0 lf0 = %v, want %v", got, tt.unnumbered)
+			}
+			if bird := slices.Index(cmds, "bird -c /etc/bird/bird.conf"); addr > bird {
+				t.Errorf("unnumbered address added after BIRD starts")
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// WhoisMatch is a node, and possibly an interface, owning an identifier.
+type WhoisMatch struct {
+	Node      string
+	Role      string
+	Interface string // Empty for node identifiers (ASN, router ID, loopbacks)
+	Peer      string // Peer node#interface, or the bridge name
+	Match     string // What matched: asn, router_id, loopback, mac, lla, address, subnet or vrf
+}
+
+// Whois returns the owners of an identifier: an IPv6 link-local address
+// (with or without %interface), a MAC, an ASN (with or without "AS") or an
+// IPv4 address. An IPv4 address that is not assigned to any node matches
+// the interfaces whose subnet contains it. The second result describes the
+// link ID encoded in a MAC or LLA (empty for other identifiers).
+func (t *Topology) Whois(id string) ([]WhoisMatch, string, error) {
+	ipam := t.IPAM()
+
+	if mac, err := net.ParseMAC(id); err == nil && len(mac) == 6 {
+		return whoisInterfaces(ipam, "mac", func(i IPAMInterface) bool {
+			return i.MAC == mac.String()
+		}), DescribeMAC(mac, t.config.RackMode), nil
+	}
+
+	if addr, err := netip.ParseAddr(id); err == nil {
+		addr = addr.WithZone("")
+		if addr.Is6() {
+			mac := LLAToMAC(addr.AsSlice())
+			if mac == nil {
+				return nil, "", fmt.Errorf("%s is not an EUI-64 link-local address", id)
+			}
+			return whoisInterfaces(ipam, "lla", func(i IPAMInterface) bool {
+				lla, err := netip.ParseAddr(i.LLA)
+				return err == nil && lla == addr
+			}), fmt.Sprintf("MAC %s, %s", mac, DescribeMAC(mac, t.config.RackMode)), nil
+		}
+		return whoisIPv4(ipam, addr), "", nil
+	}
+
+	if asn, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(id), "AS")); err == nil {
+		var matches []WhoisMatch
+		for _, n := range ipam.Nodes {
+			if n.ASN == asn {
+				matches = append(matches, WhoisMatch{Node: n.Name, Role: n.Role, Match: "asn"})
+			}
+		}
+		return matches, "", nil
+	}
+
+	return nil, "", fmt.Errorf("unrecognized identifier %q (must be an LLA, MAC, ASN or IPv4 address)", id)
+}
+
+// whoisInterfaces returns the interfaces for which match is true.
+func whoisInterfaces(ipam IPAM, kind string, match func(IPAMInterface) bool) []WhoisMatch {
+	var matches []WhoisMatch
+	for _, n := range ipam.Nodes {
+		for _, i := range n.Interfaces {
+			if match(i) {
+				matches = append(matches, WhoisMatch{
+					Node:      n.Name,
+					Role:      n.Role,
+					Interface: i.Name,
+					Peer:      whoisPeer(i),
+					Match:     kind,
+				})
+			}
+		}
+	}
+	return matches
+}
+
+// whoisIPv4 returns the owners of an IPv4 address, or the interfaces whose
+// subnet contains it.
+func whoisIPv4(ipam IPAM, addr netip.Addr) []WhoisMatch {
+	var matches []WhoisMatch
+	for _, n := range ipam.Nodes {
+		if n.RouterID == addr.String() {
+			matches = append(matches, WhoisMatch{Node: n.Name, Role: n.Role, Match: "router_id"})
+			continue
+		}
+		for _, lo := range n.Loopbacks {
+			if p, err := netip.ParsePrefix(lo); err == nil && p.Addr() == addr {
+				matches = append(matches, WhoisMatch{Node: n.Name, Role: n.Role, Interface: "lo", Match: "loopback"})
+			}
+		}
+		for _, v := range n.VRFs {
+			if p, err := netip.ParsePrefix(v.Address); err == nil && p.Addr() == addr {
+				matches = append(matches, WhoisMatch{Node: n.Name, Role: n.Role, Interface: v.Name, Match: "vrf"})
+			}
+		}
+	}
+	matches = append(matches, whoisInterfaces(ipam, "address", func(i IPAMInterface) bool {
+		p, err := netip.ParsePrefix(i.Address)
+		return err == nil && p.Addr() == addr
+	})...)
+	if len(matches) > 0 {
+		return matches
+	}
+	return whoisInterfaces(ipam, "subnet", func(i IPAMInterface) bool {
+		p, err := netip.ParsePrefix(i.Address)
+		return err == nil && p.Masked().Contains(addr)
+	})
+}
+
+// whoisPeer returns the peer of an interface as node#interface.
+func whoisPeer(i IPAMInterface) string {
+	if i.PeerInterface == "" {
+		return i.PeerNode
+	}
+	return i.PeerNode + "#" + i.PeerInterface
+}
+
+// DescribeMAC returns what a generated MAC identifies: a link end or, in
+// L2 racks, the rack gateway, and the fabric ID. Link ID 0 is not assigned
+// in other rack modes.
+func DescribeMAC(mac net.HardwareAddr, rackMode string) string {
+	if len(mac) != 6 || mac[0] != 0x02 {
+		return "not generated by clos-tinet"
+	}
+	linkID := uint32(mac[1])<<24 | uint32(mac[2])<<16 | uint32(mac[3])<<8 | uint32(mac[4])
+	if linkID == 0 && rackMode == RackModeL2 {
+		return fmt.Sprintf("rack gateway, fabric %d", mac[5])
+	}
+	return fmt.Sprintf("%s, fabric %d", DescribeLinkID(linkID), mac[5])
+}
+
+// runWhois implements the whois subcommand. It rebuilds the topology from
+// the same options and prints the owners of each identifier.
+func runWhois(args []string) {
+	fs := flag.NewFlagSet("whois", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: clos-tinet whois [flags] ID...")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "ID is an IPv6 link-local address, MAC, ASN or IPv4 address.")
+		fmt.Fprintln(fs.Output(), "Topology flags must match the ones used to generate the lab.")
+		fs.PrintDefaults()
+	}
+
+	cfg := DefaultConfig()
+	cfg.RegisterFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	topo, _, err := buildTopology(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	type result struct {
+		id      string
+		matches []WhoisMatch
+	}
+	var results []result
+	for _, id := range fs.Args() {
+		matches, desc, err := topo.Whois(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if desc != "" {
+			fmt.Printf("# %s: %s\n", id, desc)
+		}
+		if len(matches) == 0 {
+			fmt.Printf("# %s: not found in this topology\n", id)
+		}
+		results = append(results, result{id, matches})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNODE\tROLE\tINTERFACE\tPEER\tMATCH")
+	for _, r := range results {
+		for _, m := range r.matches {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.id, m.Node, m.Role, m.Interface, m.Peer, m.Match)
+		}
+	}
+	w.Flush()
+}
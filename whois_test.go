@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestWhois(t *testing.T) {
+	topo, _ := buildTestTopology(t, nil)
+
+	tests := []struct {
+		id    string
+		want  []string // node#interface
+		match string
+	}{
+		{"fe80::80:ff:fe04:100%tr0", []string{"server2-as4200100002#tr0"}, "lla"},
+		{"02:80:00:04:01:00", []string{"server2-as4200100002#tr0"}, "mac"},
+		{"4200001000", []string{"leaf1-as4200001000#", "leaf2-as4200001000#"}, "asn"},
+		{"AS4200010001", []string{"tor1-as4200010001#"}, "asn"},
+		{"10.255.0.1", []string{"spine0#"}, "router_id"},
+		{"fe80::1234:ff:fe00:1", nil, ""},
+	}
+	for _, tt := range tests {
+		matches, _, err := topo.Whois(tt.id)
+		if err != nil {
+			t.Errorf("Whois(%s): %v", tt.id, err)
+			continue
+		}
+		if len(matches) != len(tt.want) {
+			t.Errorf("Whois(%s) = %v, want %v", tt.id, matches, tt.want)
+			continue
+		}
+		for i, m := range matches {
+			if got := m.Node + "#" + m.Interface; got != tt.want[i] || m.Match != tt.match {
+				t.Errorf("Whois(%s)[%d] = %s (%s), want %s (%s)", tt.id, i, got, m.Match, tt.want[i], tt.match)
+			}
+		}
+	}
+	if m, _, _ := topo.Whois("fe80::80:ff:fe04:100"); len(m) == 1 && m[0].Peer != "tor1-as4200010001#sv0" {
+		t.Errorf("server2 tr0 peer = %s, want tor1-as4200010001#sv0", m[0].Peer)
+	}
+
+	for _, id := range []string{"fe80::1", "spine0"} {
+		if _, _, err := topo.Whois(id); err == nil {
+			t.Errorf("Whois(%s) succeeded", id)
+		}
+	}
+}
+
+func TestDescribeMAC(t *testing.T) {
+	tests := []struct {
+		mac      string
+		rackMode string
+		want     string
+	}{
+		{"02:80:00:04:01:00", RackModeL3, "tor-server link (leaf pair 0, ToR 1, server 0), lower end, fabric 0"},
+		{"02:00:00:00:00:03", RackModeL2, "rack gateway, fabric 3"},
+		{"02:00:00:00:00:03", RackModeL3, "unassigned link ID 0x0, fabric 3"},
+		{"00:12:7f:eb:6b:40", RackModeL3, "not generated by clos-tinet"},
+	}
+	for _, tt := range tests {
+		mac, _ := net.ParseMAC(tt.mac)
+		if got := DescribeMAC(mac, tt.rackMode); got != tt.want {
+			t.Errorf("DescribeMAC(%s, %s) = %q, want %q", tt.mac, tt.rackMode, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeLinkID(t *testing.T) {
+	for tier, layout := range linkIDLayouts {
+		indexes := make([]int, len(layout))
+		for i, f := range layout {
+			indexes[i] = 1<<f.bits - 1 - i
+		}
+		id := LinkID(tier, indexes...)
+		for end := 0; end < 2; end++ {
+			gotTier, got, gotEnd, ok := DecodeLinkID(id + uint32(end))
+			if !ok || gotTier != tier || gotEnd != end {
+				t.Fatalf("DecodeLinkID(%#x) = %d, %v, %d, %v", id+uint32(end), gotTier, got, gotEnd, ok)
+			}
+			for i := range indexes {
+				if got[i] != indexes[i] {
+					t.Errorf("tier %d index %d = %d, want %d", tier, i, got[i], indexes[i])
+				}
+			}
+		}
+	}
+	if _, _, _, ok := DecodeLinkID(0); ok {
+		t.Error("DecodeLinkID(0) succeeded")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import "fmt"
+
+// WorkloadBridge is the server interface holding the workload subnet.
+// Containers and network namespaces attach to it with veth pairs.
+const WorkloadBridge = "wl0"
+
+// Workloads reports whether servers get a workload subnet.
+func (c Config) Workloads() bool {
+	return c.WorkloadPrefixLen != 0
+}
+
+// validateWorkloads checks the workload prefix length and that the
+// workloads block holds a subnet per server.
+func (c Config) validateWorkloads(addrs AddressPlan) error {
+	if !c.Workloads() {
+		return nil
+	}
+	bits := c.WorkloadPrefixLen
+	if bits <= addrs.Workloads.Bits() || bits > 30 {
+		return fmt.Errorf("-workload-prefix-len must be longer than address plan workloads %s and at most 30",
+			addrs.Workloads)
+	}
+	if n := c.TotalServers(); n > 1<<(bits-addrs.Workloads.Bits()) {
+		return fmt.Errorf("address plan workloads %s holds %d /%d subnets, %d needed",
+			addrs.Workloads, 1<<(bits-addrs.Workloads.Bits()), bits, n)
+	}
+	return nil
+}
+
+// WorkloadSubnet returns the workload subnet of a server.
+func (p AddressPlan) WorkloadSubnet(index, bits int) string {
+	size := 1 << (32 - bits)
+	return formatPrefix(p.Workloads.offset(index*size), size)
+}
+
+// workloadCmds returns the commands that create the workload bridge.
+func workloadCmds(gateway string) []Command {
+	return []Command{
+		{Cmd: fmt.Sprintf("ip link add %s type bridge", WorkloadBridge)},
+		{Cmd: fmt.Sprintf("ip addr add %s dev %s", gateway, WorkloadBridge)},
+		{Cmd: fmt.Sprintf("ip link set dev %s up", WorkloadBridge)},
+	}
+}